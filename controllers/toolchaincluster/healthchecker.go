@@ -26,12 +26,26 @@ const (
 	healthzNotOk           = "/healthz responded without ok"
 	clusterNotReachableMsg = "cluster is not reachable"
 	clusterReachableMsg    = "cluster is reachable"
+	clusterInsecureMsg     = "the certificate of the cluster is not verified"
+
+	// ToolchainClusterInsecure the type of the condition set when the connection to the cluster is insecure,
+	// ie, when the ToolchainCluster opted in for skipping the verification of the certificate of the cluster
+	ToolchainClusterInsecure toolchainv1alpha1.ToolchainClusterConditionType = "Insecure"
+	// ToolchainClusterInsecureSkipTLSVerifyReason the reason of the condition set when the connection to the cluster is insecure
+	ToolchainClusterInsecureSkipTLSVerifyReason = "InsecureSkipTLSVerify"
 )
 
+// StartHealthChecks periodically checks the health of the clusters of the default cache (see `StartHealthChecksWithCache`)
 func StartHealthChecks(ctx context.Context, mgr manager.Manager, namespace string, period time.Duration) {
+	StartHealthChecksWithCache(ctx, mgr, cluster.DefaultClusterCache(), namespace, period)
+}
+
+// StartHealthChecksWithCache periodically checks the health of the clusters of the given cache
+// and updates the status of the corresponding ToolchainClusters, until the given context is done
+func StartHealthChecksWithCache(ctx context.Context, mgr manager.Manager, cache *cluster.ClusterCache, namespace string, period time.Duration) {
 	logger.Info("starting health checks", "period", period)
 	go wait.Until(func() {
-		updateClusterStatuses(namespace, mgr.GetClient())
+		updateClusterStatuses(cache, namespace, mgr.GetClient())
 	}, period, ctx.Done())
 }
 
@@ -39,11 +53,12 @@ type HealthChecker struct {
 	localClusterClient     client.Client
 	remoteClusterClient    client.Client
 	remoteClusterClientset *kubeclientset.Clientset
+	insecure               bool
 	logger                 logr.Logger
 }
 
-// updateClusterStatuses checks cluster health and updates status of all ToolchainClusters
-func updateClusterStatuses(namespace string, cl client.Client) {
+// updateClusterStatuses checks cluster health (using the clients of the given cache) and updates status of all ToolchainClusters
+func updateClusterStatuses(cache *cluster.ClusterCache, namespace string, cl client.Client) {
 	clusters := &toolchainv1alpha1.ToolchainClusterList{}
 	err := cl.List(context.TODO(), clusters, client.InNamespace(namespace))
 	if err != nil {
@@ -58,7 +73,7 @@ func updateClusterStatuses(namespace string, cl client.Client) {
 		clusterObj := obj.DeepCopy()
 		clusterLogger := logger.WithValues("cluster-name", clusterObj.Name)
 
-		cachedCluster, ok := cluster.GetCachedToolchainCluster(clusterObj.Name)
+		cachedCluster, ok := cache.GetCachedToolchainCluster(clusterObj.Name)
 		if !ok {
 			clusterLogger.Error(fmt.Errorf("cluster %s not found in cache", clusterObj.Name), "failed to retrieve stored data for cluster")
 			clusterObj.Status.Conditions = []toolchainv1alpha1.ToolchainClusterCondition{clusterOfflineCondition()}
@@ -78,6 +93,7 @@ func updateClusterStatuses(namespace string, cl client.Client) {
 			localClusterClient:     cl,
 			remoteClusterClient:    cachedCluster.Client,
 			remoteClusterClientset: clientSet,
+			insecure:               cachedCluster.RestConfig.Insecure,
 			logger:                 clusterLogger,
 		}
 		// clusterLogger.Info("getting the current state of ToolchainCluster")
@@ -90,6 +106,9 @@ func updateClusterStatuses(namespace string, cl client.Client) {
 func (hc *HealthChecker) updateIndividualClusterStatus(toolchainCluster *toolchainv1alpha1.ToolchainCluster) error {
 
 	currentClusterStatus := hc.getClusterHealthStatus()
+	if hc.insecure {
+		currentClusterStatus.Conditions = append(currentClusterStatus.Conditions, clusterInsecureCondition())
+	}
 
 	for index, currentCond := range currentClusterStatus.Conditions {
 		for _, previousCond := range toolchainCluster.Status.Conditions {
@@ -171,3 +190,15 @@ func clusterNotOfflineCondition() toolchainv1alpha1.ToolchainClusterCondition {
 		LastTransitionTime: &currentTime,
 	}
 }
+
+func clusterInsecureCondition() toolchainv1alpha1.ToolchainClusterCondition {
+	currentTime := metav1.Now()
+	return toolchainv1alpha1.ToolchainClusterCondition{
+		Type:               ToolchainClusterInsecure,
+		Status:             corev1.ConditionTrue,
+		Reason:             ToolchainClusterInsecureSkipTLSVerifyReason,
+		Message:            clusterInsecureMsg,
+		LastProbeTime:      currentTime,
+		LastTransitionTime: &currentTime,
+	}
+}
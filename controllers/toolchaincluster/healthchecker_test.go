@@ -2,6 +2,8 @@ package toolchaincluster
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
@@ -40,11 +42,10 @@ func TestClusterHealthChecks(t *testing.T) {
 		stable, _ := newToolchainCluster("stable", "http://cluster.com", toolchainv1alpha1.ToolchainClusterStatus{})
 
 		cl := test.NewFakeClient(t, unstable, notFound, stable, sec)
-		reset := setupCachedClusters(t, cl, unstable, notFound, stable)
-		defer reset()
+		cache := setupCachedClusters(t, cl, unstable, notFound, stable)
 
 		// when
-		updateClusterStatuses("test-namespace", cl)
+		updateClusterStatuses(cache, "test-namespace", cl)
 
 		// then
 		assertClusterStatus(t, cl, "unstable", notOffline(), unhealthy())
@@ -58,11 +59,10 @@ func TestClusterHealthChecks(t *testing.T) {
 		stable, _ := newToolchainCluster("stable", "http://cluster.com", withStatus(offline()))
 
 		cl := test.NewFakeClient(t, unstable, notFound, stable, sec)
-		resetCache := setupCachedClusters(t, cl, unstable, notFound, stable)
-		defer resetCache()
+		cache := setupCachedClusters(t, cl, unstable, notFound, stable)
 
 		// when
-		updateClusterStatuses("test-namespace", cl)
+		updateClusterStatuses(cache, "test-namespace", cl)
 
 		// then
 		assertClusterStatus(t, cl, "unstable", notOffline(), unhealthy())
@@ -74,47 +74,69 @@ func TestClusterHealthChecks(t *testing.T) {
 		stable, sec := newToolchainCluster("stable", "http://cluster.com", withStatus(offline()))
 
 		cl := test.NewFakeClient(t, stable, sec)
-		resetCache := setupCachedClusters(t, cl, stable)
-		defer resetCache()
+		cache := setupCachedClusters(t, cl, stable)
 
 		// when
-		updateClusterStatuses("test-namespace", cl)
+		updateClusterStatuses(cache, "test-namespace", cl)
 
 		// then
 		assertClusterStatus(t, cl, "stable", healthy())
 	})
 
+	t.Run("insecure connection is surfaced as a condition", func(t *testing.T) {
+		// given
+		// the insecure connections don't use the default transport, hence they are not intercepted by gock
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write([]byte("ok"))
+			require.NoError(t, err)
+		}))
+		defer server.Close()
+		insecure, sec := newToolchainCluster("insecure", server.URL, toolchainv1alpha1.ToolchainClusterStatus{})
+		insecure.Annotations = map[string]string{cluster.InsecureSkipTLSVerifyAnnotation: "true"}
+		stable, _ := newToolchainCluster("stable", "http://cluster.com", toolchainv1alpha1.ToolchainClusterStatus{})
+
+		cl := test.NewFakeClient(t, insecure, stable, sec)
+		cache := setupCachedClusters(t, cl, insecure, stable)
+
+		// when
+		updateClusterStatuses(cache, "test-namespace", cl)
+
+		// then
+		assertClusterStatus(t, cl, "insecure", healthy(), insecureConnection())
+		assertClusterStatus(t, cl, "stable", healthy())
+	})
+
 	t.Run("if the connection cannot be established at beginning, then it should be offline", func(t *testing.T) {
 		stable, sec := newToolchainCluster("failing", "http://failing.com", toolchainv1alpha1.ToolchainClusterStatus{})
 
 		cl := test.NewFakeClient(t, stable, sec)
+		cache := cluster.NewClusterCache()
 
 		// when
-		updateClusterStatuses("test-namespace", cl)
+		updateClusterStatuses(cache, "test-namespace", cl)
 
 		// then
 		assertClusterStatus(t, cl, "failing", offline())
 	})
 }
 
-func setupCachedClusters(t *testing.T, cl *test.FakeClient, clusters ...*toolchainv1alpha1.ToolchainCluster) func() {
-	service := cluster.NewToolchainClusterServiceWithClient(cl, logf.Log, "test-namespace", 0, func(config *rest.Config, options client.Options) (client.Client, error) {
+func setupCachedClusters(t *testing.T, cl *test.FakeClient, clusters ...*toolchainv1alpha1.ToolchainCluster) *cluster.ClusterCache {
+	cache := cluster.NewClusterCache()
+	service := cluster.NewToolchainClusterServiceWithCache(cache, cl, logf.Log, "test-namespace", 0, func(config *rest.Config, options client.Options) (client.Client, error) {
 		// make sure that insecure is false to make Gock mocking working properly
-		config.Insecure = false
-		return client.New(config, options)
+		// let's use a copy of the config, so it doesn't affect the cache logic
+		copiedConfig := rest.CopyConfig(config)
+		copiedConfig.Insecure = false
+		return client.New(copiedConfig, options)
 	})
 	for _, clustr := range clusters {
 		err := service.AddOrUpdateToolchainCluster(clustr)
 		require.NoError(t, err)
-		tc, found := cluster.GetCachedToolchainCluster(clustr.Name)
+		tc, found := cache.GetCachedToolchainCluster(clustr.Name)
 		require.True(t, found)
 		tc.Client = test.NewFakeClient(t)
 	}
-	return func() {
-		for _, clustr := range clusters {
-			service.DeleteToolchainCluster(clustr.Name)
-		}
-	}
+	return cache
 }
 
 func withStatus(conditions ...toolchainv1alpha1.ToolchainClusterCondition) toolchainv1alpha1.ToolchainClusterStatus {
@@ -169,6 +191,13 @@ func offline() toolchainv1alpha1.ToolchainClusterCondition {
 		Message: "cluster is not reachable",
 	}
 }
+func insecureConnection() toolchainv1alpha1.ToolchainClusterCondition {
+	return toolchainv1alpha1.ToolchainClusterCondition{Type: ToolchainClusterInsecure,
+		Status:  corev1.ConditionTrue,
+		Reason:  "InsecureSkipTLSVerify",
+		Message: "the certificate of the cluster is not verified",
+	}
+}
 func notOffline() toolchainv1alpha1.ToolchainClusterCondition {
 	return toolchainv1alpha1.ToolchainClusterCondition{Type: toolchainv1alpha1.ToolchainClusterOffline,
 		Status:  corev1.ConditionFalse,
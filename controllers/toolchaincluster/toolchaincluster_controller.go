@@ -7,37 +7,130 @@ import (
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
-// NewReconciler returns a new Reconciler
-func NewReconciler(mgr manager.Manager, namespace string, timeout time.Duration) *Reconciler {
+// ReconcilerOption an option to configure the Reconciler
+type ReconcilerOption func(*reconcilerConfig)
+
+type reconcilerConfig struct {
+	cache *cluster.ClusterCache
+	// cachedClientsCtx the context of the cached clients, if enabled
+	cachedClientsCtx context.Context
+}
+
+// ClusterCache sets the cache of the clusters filled by the Reconciler (default: `cluster.DefaultClusterCache()`)
+func ClusterCache(cache *cluster.ClusterCache) ReconcilerOption {
+	return func(config *reconcilerConfig) {
+		config.cache = cache
+	}
+}
+
+// CachedClients enables the cached clients of the clusters (see `cluster.NewToolchainClusterServiceWithCachedClients`).
+// The clusters are stopped when the given context is done.
+func CachedClients(ctx context.Context) ReconcilerOption {
+	return func(config *reconcilerConfig) {
+		config.cachedClientsCtx = ctx
+	}
+}
+
+// NewReconciler returns a new Reconciler configured with the given options
+func NewReconciler(mgr manager.Manager, namespace string, timeout time.Duration, options ...ReconcilerOption) *Reconciler {
+	config := &reconcilerConfig{
+		cache: cluster.DefaultClusterCache(),
+	}
+	for _, configure := range options {
+		configure(config)
+	}
 	cacheLog := log.Log.WithName("toolchaincluster_cache")
-	clusterCacheService := cluster.NewToolchainClusterService(mgr.GetClient(), cacheLog, namespace, timeout)
+	var clusterCacheService cluster.ToolchainClusterService
+	if config.cachedClientsCtx != nil {
+		clusterCacheService = cluster.NewToolchainClusterServiceWithCachedClients(config.cachedClientsCtx, config.cache, mgr.GetClient(), cacheLog, namespace, timeout, nil, nil)
+	} else {
+		clusterCacheService = cluster.NewToolchainClusterServiceWithCache(config.cache, mgr.GetClient(), cacheLog, namespace, timeout, nil)
+	}
 	return &Reconciler{
 		client:              mgr.GetClient(),
 		scheme:              mgr.GetScheme(),
+		namespace:           namespace,
 		clusterCacheService: clusterCacheService,
 	}
 }
 
+// secretRefNameIndex the name of the index of the ToolchainClusters by the name of the Secret they refer to
+const secretRefNameIndex = "spec.secretRef.name"
+
+// indexSecretRefName returns the name of the Secret the given ToolchainCluster refers to
+func indexSecretRefName(obj client.Object) []string {
+	toolchainCluster, ok := obj.(*toolchainv1alpha1.ToolchainCluster)
+	if !ok || toolchainCluster.Spec.SecretRef.Name == "" {
+		return nil
+	}
+	return []string{toolchainCluster.Spec.SecretRef.Name}
+}
+
 // SetupWithManager sets up the controller with the Manager.
+// The Secrets referenced by the ToolchainClusters are watched too, so that the cached clients are rebuilt when the credentials are rotated.
+// Note: only the events of the Secrets in the operator namespace are processed, but the Secrets are watched (and read) through the cache
+// of the Manager, so the cache of the Manager must be restricted to the operator namespace (eg, with `manager.Options.Namespace`).
+// Otherwise, all the Secrets of the cluster are cached, which requires the permission to list and watch the Secrets in all the namespaces.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.TODO(), &toolchainv1alpha1.ToolchainCluster{}, secretRefNameIndex, indexSecretRefName); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&toolchainv1alpha1.ToolchainCluster{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.mapSecretToToolchainClusters),
+			builder.WithPredicates(predicate.NewPredicateFuncs(r.inNamespace))).
 		Complete(r)
 }
 
+// inNamespace checks that the given object is in the namespace of the ToolchainClusters.
+// It only filters the events, it does not restrict the objects watched by the cache of the Manager (see `SetupWithManager`)
+func (r *Reconciler) inNamespace(obj client.Object) bool {
+	return obj.GetNamespace() == r.namespace
+}
+
+// mapSecretToToolchainClusters maps the given Secret to requests on the ToolchainClusters (in the same namespace) which refer to it,
+// using the index of the ToolchainClusters by the name of their Secret (see `secretRefNameIndex`)
+func (r *Reconciler) mapSecretToToolchainClusters(secret client.Object) []reconcile.Request {
+	toolchainClusters := &toolchainv1alpha1.ToolchainClusterList{}
+	if err := r.client.List(context.TODO(), toolchainClusters,
+		client.InNamespace(secret.GetNamespace()),
+		client.MatchingFields{secretRefNameIndex: secret.GetName()}); err != nil {
+		log.Log.Error(err, "unable to list the ToolchainClusters referring to the secret", "Secret.Namespace", secret.GetNamespace(), "Secret.Name", secret.GetName())
+		return []reconcile.Request{}
+	}
+	requests := make([]reconcile.Request, 0, len(toolchainClusters.Items))
+	for _, toolchainCluster := range toolchainClusters.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: toolchainCluster.Namespace,
+				Name:      toolchainCluster.Name,
+			},
+		})
+	}
+	return requests
+}
+
 // Reconciler reconciles a ToolchainCluster object
 type Reconciler struct {
 	client              client.Client
 	scheme              *runtime.Scheme
+	namespace           string
 	clusterCacheService cluster.ToolchainClusterService
 }
 
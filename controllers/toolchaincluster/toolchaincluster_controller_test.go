@@ -3,12 +3,21 @@ package toolchaincluster
 import (
 	"context"
 	"testing"
+	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test/verify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -87,10 +96,101 @@ func TestDeleteToolchainCluster(t *testing.T) {
 	})
 }
 
+func TestSecretRotation(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ToolchainClusterReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster("east", "secret", status, verify.Labels(cluster.Member, "", test.NameHost))
+	other, otherSec := test.NewToolchainCluster("west", "other-secret", status, verify.Labels(cluster.Member, "", test.NameHost))
+	cl := test.NewFakeClient(t, toolchainCluster, sec, other, otherSec)
+	mockSecretRefNameIndex(cl)
+	cache := cluster.NewClusterCache()
+	service := cluster.NewToolchainClusterServiceWithCache(cache, cl, logf.Log, "test-namespace", 3*time.Second, func(config *rest.Config, options client.Options) (client.Client, error) {
+		// make sure that insecure is false to make Gock mocking working properly
+		// let's use a copy of the config, so it doesn't affect the cache logic
+		copiedConfig := rest.CopyConfig(config)
+		copiedConfig.Insecure = false
+		return client.New(copiedConfig, options)
+	})
+	controller, req := prepareReconcile(toolchainCluster, cl, service)
+	_, err := controller.Reconcile(context.TODO(), req)
+	require.NoError(t, err)
+	cachedCluster, ok := cache.GetCachedToolchainCluster("east")
+	require.True(t, ok)
+	originalClient := cachedCluster.Client
+	originalResourceVersion := cachedCluster.SecretResourceVersion
+	require.NotEmpty(t, originalResourceVersion)
+
+	t.Run("secret is mapped to the ToolchainClusters referring to it", func(t *testing.T) {
+		// when
+		requests := controller.mapSecretToToolchainClusters(sec)
+
+		// then
+		assert.Equal(t, []reconcile.Request{req}, requests)
+	})
+
+	t.Run("unknown secret is not mapped", func(t *testing.T) {
+		// given
+		unknown := sec.DeepCopy()
+		unknown.Name = "unknown"
+
+		// when
+		requests := controller.mapSecretToToolchainClusters(unknown)
+
+		// then
+		assert.Empty(t, requests)
+	})
+
+	t.Run("only secrets in the operator namespace are watched", func(t *testing.T) {
+		// given
+		otherNamespace := sec.DeepCopy()
+		otherNamespace.Namespace = "other-namespace"
+
+		// when & then
+		assert.True(t, controller.inNamespace(sec))
+		assert.False(t, controller.inNamespace(otherNamespace))
+	})
+
+	t.Run("client is not rebuilt when the secret is unchanged", func(t *testing.T) {
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		cachedCluster, ok := cache.GetCachedToolchainCluster("east")
+		require.True(t, ok)
+		assert.Same(t, originalClient, cachedCluster.Client)
+		assert.Equal(t, originalResourceVersion, cachedCluster.SecretResourceVersion)
+	})
+
+	t.Run("client is rebuilt when the token is rotated", func(t *testing.T) {
+		// given
+		rotated := &corev1.Secret{}
+		require.NoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(sec), rotated))
+		rotated.Data["token"] = []byte("rotated-token")
+		require.NoError(t, cl.Update(context.TODO(), rotated))
+
+		// when
+		for _, r := range controller.mapSecretToToolchainClusters(rotated) {
+			_, err := controller.Reconcile(context.TODO(), r)
+			require.NoError(t, err)
+		}
+
+		// then
+		cachedCluster, ok := cache.GetCachedToolchainCluster("east")
+		require.True(t, ok)
+		assert.NotSame(t, originalClient, cachedCluster.Client)
+		assert.Equal(t, "rotated-token", cachedCluster.RestConfig.BearerToken)
+		assert.Equal(t, rotated.ResourceVersion, cachedCluster.SecretResourceVersion)
+		assert.NotEqual(t, originalResourceVersion, cachedCluster.SecretResourceVersion)
+	})
+}
+
 func prepareReconcile(toolchainCluster *toolchainv1alpha1.ToolchainCluster, cl *test.FakeClient, service cluster.ToolchainClusterService) (Reconciler, reconcile.Request) {
 	controller := Reconciler{
 		client:              cl,
 		scheme:              scheme.Scheme,
+		namespace:           toolchainCluster.Namespace,
 		clusterCacheService: service,
 	}
 	req := reconcile.Request{
@@ -98,3 +198,29 @@ func prepareReconcile(toolchainCluster *toolchainv1alpha1.ToolchainCluster, cl *
 	}
 	return controller, req
 }
+
+// mockSecretRefNameIndex makes the fake client filter the ToolchainClusters by the name of their Secret,
+// since the field selectors are ignored by the fake client
+func mockSecretRefNameIndex(cl *test.FakeClient) {
+	cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		if err := cl.Client.List(ctx, list, opts...); err != nil {
+			return err
+		}
+		listOptions := &client.ListOptions{}
+		listOptions.ApplyOptions(opts)
+		toolchainClusters, ok := list.(*toolchainv1alpha1.ToolchainClusterList)
+		if !ok || listOptions.FieldSelector == nil {
+			return nil
+		}
+		items := toolchainClusters.Items[:0]
+		for i := range toolchainClusters.Items {
+			for _, value := range indexSecretRefName(&toolchainClusters.Items[i]) {
+				if listOptions.FieldSelector.Matches(fields.Set{secretRefNameIndex: value}) {
+					items = append(items, toolchainClusters.Items[i])
+				}
+			}
+		}
+		toolchainClusters.Items = items
+		return nil
+	}
+}
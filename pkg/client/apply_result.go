@@ -0,0 +1,97 @@
+package client
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ApplyOperation what happened to an object when it was applied
+type ApplyOperation string
+
+const (
+	// ApplyCreated the object did not exist and was created
+	ApplyCreated ApplyOperation = "created"
+	// ApplyUpdated the object was updated, and its generation was incremented by the server (ie, its spec or data changed)
+	ApplyUpdated ApplyOperation = "updated"
+	// ApplyMetadataUpdated the object was updated, but only its metadata (labels, annotations, owner references or finalizers) changed,
+	// so its generation was not incremented by the server
+	ApplyMetadataUpdated ApplyOperation = "metadata-updated"
+	// ApplyUnchanged the object already existed and did not change
+	ApplyUnchanged ApplyOperation = "unchanged"
+	// ApplyRecreated the object was deleted and created again
+	ApplyRecreated ApplyOperation = "recreated"
+	// ApplyFailed the object could not be applied (see `ApplyResult.Err`)
+	ApplyFailed ApplyOperation = "failed"
+)
+
+// ApplyResult the result of the apply of a single object
+type ApplyResult struct {
+	// Object the resulting object, as returned by the server, or the given object if it could not be applied
+	Object client.Object
+	// GVK the GroupVersionKind of the object
+	GVK schema.GroupVersionKind
+	// Operation what happened to the object
+	Operation ApplyOperation
+	// Err the error which occurred when the object was applied, if any (the operation is then `ApplyFailed`)
+	Err error
+}
+
+// CreatedOrUpdated returns `true` if the object was created, updated (including its metadata only) or recreated,
+// `false` if it did not change or could not be applied
+func (r ApplyResult) CreatedOrUpdated() bool {
+	return r.Operation != "" && r.Operation != ApplyUnchanged && r.Operation != ApplyFailed
+}
+
+// ApplyResults the results of the apply of multiple objects
+type ApplyResults []ApplyResult
+
+// CreatedOrUpdated returns `true` if at least one of the objects was created, updated (including its metadata only) or recreated,
+// `false` if none of them changed
+func (r ApplyResults) CreatedOrUpdated() bool {
+	for _, result := range r {
+		if result.CreatedOrUpdated() {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed returns the results of the objects which could not be applied
+func (r ApplyResults) Failed() ApplyResults {
+	var failed ApplyResults
+	for _, result := range r {
+		if result.Operation == ApplyFailed {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// updateOperation returns the operation which happened to the given existing object when it was updated, based on the given updated object
+// returned by the server: `ApplyUpdated` if the generation was incremented, `ApplyMetadataUpdated` if only the metadata changed,
+// or `ApplyUnchanged` otherwise.
+// Note: the last applied configuration annotation is ignored, since it is only used for the bookkeeping of the apply.
+func updateOperation(existing, updated client.Object) ApplyOperation {
+	switch {
+	case existing.GetGeneration() != updated.GetGeneration():
+		return ApplyUpdated
+	case !equality.Semantic.DeepEqual(existing.GetLabels(), updated.GetLabels()) ||
+		!equality.Semantic.DeepEqual(withoutLastAppliedConfiguration(existing), withoutLastAppliedConfiguration(updated)) ||
+		!equality.Semantic.DeepEqual(existing.GetOwnerReferences(), updated.GetOwnerReferences()) ||
+		!equality.Semantic.DeepEqual(existing.GetFinalizers(), updated.GetFinalizers()):
+		return ApplyMetadataUpdated
+	default:
+		return ApplyUnchanged
+	}
+}
+
+func withoutLastAppliedConfiguration(obj client.Object) map[string]string {
+	annotations := make(map[string]string, len(obj.GetAnnotations()))
+	for k, v := range obj.GetAnnotations() {
+		if k != LastAppliedConfigurationAnnotationKey {
+			annotations[k] = v
+		}
+	}
+	return annotations
+}
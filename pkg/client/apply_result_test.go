@@ -0,0 +1,128 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyResult(t *testing.T) {
+	// given
+	addToScheme(t)
+
+	for name, options := range map[string][]client.ApplyObjectOption{
+		"using update":                {client.ForceUpdate(true)},
+		"using three-way merge patch": {client.ForceUpdate(true), client.ThreeWayMergePatch(true)},
+	} {
+		t.Run(name, func(t *testing.T) {
+
+			t.Run("created", func(t *testing.T) {
+				// given
+				cl, _ := newClient(t)
+
+				// when
+				result, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}), options...)
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyCreated, result.Operation)
+				assert.Equal(t, corev1.SchemeGroupVersion.WithKind("ConfigMap"), result.GVK)
+				assert.Equal(t, "cm", result.Object.GetName())
+				assert.True(t, result.CreatedOrUpdated())
+			})
+
+			t.Run("metadata updated", func(t *testing.T) {
+				// given
+				cl, _ := newClient(t)
+				_, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}), options...)
+				require.NoError(t, err)
+
+				// when
+				result, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}, withLabels(map[string]string{"foo": "bar"})), options...)
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyMetadataUpdated, result.Operation)
+				assert.Equal(t, "bar", result.Object.GetLabels()["foo"])
+				assert.True(t, result.CreatedOrUpdated())
+			})
+
+			t.Run("unchanged", func(t *testing.T) {
+				// given
+				cl, _ := newClient(t)
+				_, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}), options...)
+				require.NoError(t, err)
+
+				// when
+				result, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}), options...)
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyUnchanged, result.Operation)
+				assert.False(t, result.CreatedOrUpdated())
+			})
+		})
+	}
+
+	t.Run("updated", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		_, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}))
+		require.NoError(t, err)
+
+		// when
+		result, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "other-value"}))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyUpdated, result.Operation)
+		assert.Equal(t, "other-value", result.Object.(*corev1.ConfigMap).Data["param"])
+		assert.True(t, result.CreatedOrUpdated())
+	})
+
+	t.Run("unchanged without forcing the update", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		_, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}))
+		require.NoError(t, err)
+
+		// when
+		result, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyUnchanged, result.Operation)
+		// the existing object is returned
+		assert.NotEmpty(t, result.Object.GetResourceVersion())
+	})
+
+	t.Run("multiple objects", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		labels := newLabels("base1ns", "john", "dev")
+		_, err := cl.Apply([]runtimeclient.Object{newConfigMap("john-dev", "cm", map[string]string{"param": "value"})}, labels)
+		require.NoError(t, err)
+
+		// when
+		results, err := cl.Apply([]runtimeclient.Object{
+			newConfigMap("john-dev", "cm", map[string]string{"param": "value"}),
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "john-dev"}},
+		}, labels)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		// same order as the given objects
+		assert.Equal(t, "cm", results[0].Object.GetName())
+		assert.Equal(t, client.ApplyUnchanged, results[0].Operation)
+		assert.Equal(t, "john-dev", results[1].Object.GetName())
+		assert.Equal(t, client.ApplyCreated, results[1].Operation)
+		assert.True(t, results.CreatedOrUpdated())
+		assert.False(t, results[:1].CreatedOrUpdated())
+	})
+}
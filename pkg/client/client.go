@@ -4,10 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	corev1 "k8s.io/api/core/v1"
@@ -23,22 +32,63 @@ const LastAppliedConfigurationAnnotationKey = "toolchain.dev.openshift.com/last-
 
 var log = logf.Log.WithName("apply_client")
 
+// DefaultMaxConcurrentApplies the default maximum number of objects applied concurrently by `ApplyClient.Apply`
+const DefaultMaxConcurrentApplies = 5
+
 // ApplyClient the client to use when creating or updating objects
 type ApplyClient struct {
 	client.Client
+	maxConcurrentApplies int
+	conflictRetryBackoff *wait.Backoff
+	eventRecorder        record.EventRecorder
+	clusterName          string
+}
+
+// ApplyClientOption an option when creating an ApplyClient
+type ApplyClientOption func(*ApplyClient)
+
+// MaxConcurrentApplies sets the maximum number of objects of the same kind priority which are applied concurrently
+// by `ApplyClient.Apply` (default: `DefaultMaxConcurrentApplies`). A value lower than `1` means the default.
+func MaxConcurrentApplies(maxConcurrentApplies int) ApplyClientOption {
+	return func(c *ApplyClient) {
+		c.maxConcurrentApplies = maxConcurrentApplies
+	}
+}
+
+// ConflictRetryBackoff sets the backoff to use when retrying to apply an object after the server returned a `Conflict` error,
+// ie, when the object was modified by another party in the meantime (default: `retry.DefaultRetry` of the client-go library)
+func ConflictRetryBackoff(backoff wait.Backoff) ApplyClientOption {
+	return func(c *ApplyClient) {
+		c.conflictRetryBackoff = &backoff
+	}
 }
 
 // NewApplyClient returns a new ApplyClient
-func NewApplyClient(cl client.Client) *ApplyClient {
-	return &ApplyClient{
-		Client: cl,
+func NewApplyClient(cl client.Client, options ...ApplyClientOption) *ApplyClient {
+	c := &ApplyClient{
+		Client:               cl,
+		maxConcurrentApplies: DefaultMaxConcurrentApplies,
+	}
+	for _, apply := range options {
+		apply(c)
 	}
+	return c
 }
 
 type applyObjectConfiguration struct {
 	owner             v1.Object
 	forceUpdate       bool
 	saveConfiguration bool
+	serverSideApply   bool
+	fieldManager      string
+	forceConflicts    bool
+	threeWayMerge     bool
+	dryRun            bool
+	// the encoding of the last applied configuration annotation
+	configurationEncoding ConfigurationEncoding
+	// recreate the resource when an immutable field changed
+	recreateOnImmutableChange bool
+	recreateTimeout           time.Duration
 }
 
 func newApplyObjectConfiguration(options ...ApplyObjectOption) applyObjectConfiguration {
@@ -46,6 +96,15 @@ func newApplyObjectConfiguration(options ...ApplyObjectOption) applyObjectConfig
 		owner:             nil,
 		forceUpdate:       false,
 		saveConfiguration: true,
+		serverSideApply:   false,
+		fieldManager:      DefaultFieldManager,
+		forceConflicts:    false,
+		threeWayMerge:     false,
+		dryRun:            false,
+
+		configurationEncoding:     PlainConfigurationEncoding,
+		recreateOnImmutableChange: false,
+		recreateTimeout:           DefaultRecreateTimeout,
 	}
 	for _, apply := range options {
 		apply(&config)
@@ -78,42 +137,152 @@ func SaveConfiguration(saveConfiguration bool) ApplyObjectOption {
 	}
 }
 
+// ServerSideApply uses the Kubernetes server-side apply with the given field manager to create or update the resource,
+// instead of comparing the last applied configuration annotation and updating the whole resource (default: disabled).
+// If the given field manager is empty, then the DefaultFieldManager is used.
+// Note: when server-side apply is used, the last applied configuration is not saved in the resource annotations.
+func ServerSideApply(fieldManager string) ApplyObjectOption {
+	return func(config *applyObjectConfiguration) {
+		config.serverSideApply = true
+		if fieldManager != "" {
+			config.fieldManager = fieldManager
+		}
+	}
+}
+
+// ForceConflicts forces the server-side apply to take the ownership of the fields
+// which are managed by other field managers (default: `false`).
+// It has no effect when the server-side apply is not enabled.
+func ForceConflicts(forceConflicts bool) ApplyObjectOption {
+	return func(config *applyObjectConfiguration) {
+		config.forceConflicts = forceConflicts
+	}
+}
+
+// ThreeWayMergePatch patches the existing resource with a three-way merge patch computed from the last applied configuration,
+// the new resource and the existing resource, instead of updating the whole resource (default: `false`).
+// As a result, the fields which were removed since the last applied configuration are removed from the resource,
+// while the fields which were set by other parties (controllers, admission webhooks, etc.) are retained.
+// A strategic merge patch is used for the Kubernetes built-in types, and a JSON merge patch for all other types.
+// Note: this option should be used along with the `SaveConfiguration(true)` option (default), otherwise no field can be removed.
+func ThreeWayMergePatch(threeWayMerge bool) ApplyObjectOption {
+	return func(config *applyObjectConfiguration) {
+		config.threeWayMerge = threeWayMerge
+	}
+}
+
+// RecreateOnImmutableChange deletes and recreates the resource when the value of an immutable field changed (default: `false`),
+// eg, the `spec.selector` of a Deployment or the `roleRef` of a RoleBinding (see `RegisterImmutableFields`).
+// The resource is created again once the deletion completed, and the result of the apply is `ApplyRecreated`.
+// Note: this option has no effect when the server-side apply is enabled.
+func RecreateOnImmutableChange(recreate bool) ApplyObjectOption {
+	return func(config *applyObjectConfiguration) {
+		config.recreateOnImmutableChange = recreate
+	}
+}
+
+// RecreateTimeout sets the maximum duration to wait for the deletion of a resource before it is created again,
+// when the `RecreateOnImmutableChange` option is enabled (default: `DefaultRecreateTimeout`)
+func RecreateTimeout(timeout time.Duration) ApplyObjectOption {
+	return func(config *applyObjectConfiguration) {
+		config.recreateTimeout = timeout
+	}
+}
+
 // ApplyRuntimeObject casts the provided object to client.Object and calls ApplyClient.ApplyObject method
-func (c ApplyClient) ApplyRuntimeObject(obj runtime.Object, options ...ApplyObjectOption) (bool, error) {
+func (c ApplyClient) ApplyRuntimeObject(obj runtime.Object, options ...ApplyObjectOption) (ApplyResult, error) {
+	return c.ApplyRuntimeObjectCtx(context.TODO(), obj, options...)
+}
+
+// ApplyRuntimeObjectCtx casts the provided object to client.Object and calls ApplyClient.ApplyObjectCtx method
+func (c ApplyClient) ApplyRuntimeObjectCtx(ctx context.Context, obj runtime.Object, options ...ApplyObjectOption) (ApplyResult, error) {
 	clientObj, ok := obj.(client.Object)
 	if !ok {
-		return false, fmt.Errorf("unable to cast of the object to client.Object: %+v", obj)
+		return ApplyResult{}, fmt.Errorf("unable to cast of the object to client.Object: %+v", obj)
 	}
-	return c.applyObject(clientObj, options...)
+	return c.applyObjectWithRetries(ctx, clientObj, options...)
 }
 
 // ApplyObject creates the object if is missing and if the owner object is provided, then it's set as a controller reference.
 // If the objects exists then when the spec content has changed (based on the content of the annotation in the original object) then it
 // is automatically updated. If it looks to be same then based on the value of forceUpdate param it updates the object or not.
-// The return boolean says if the object was either created or updated (`true`). If nothing changed (ie, the generation was not
-// incremented by the server), then it returns `false`.
-func (c ApplyClient) ApplyObject(obj client.Object, options ...ApplyObjectOption) (bool, error) {
+// The returned result contains the resulting object and says if it was created, updated (ie, the generation was incremented by the server),
+// updated on its metadata only, or unchanged.
+func (c ApplyClient) ApplyObject(obj client.Object, options ...ApplyObjectOption) (ApplyResult, error) {
+	return c.ApplyObjectCtx(context.TODO(), obj, options...)
+}
+
+// ApplyObjectCtx is the same as ApplyObject, but all the requests to the server are bound to the given context,
+// so that the apply is aborted as soon as the context is cancelled or its deadline is exceeded.
+func (c ApplyClient) ApplyObjectCtx(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (ApplyResult, error) {
 	gvk := obj.GetObjectKind().GroupVersionKind()
-	createdOrUpdated, err := c.applyObject(obj, options...)
+	result, err := c.applyObjectWithRetries(ctx, obj, options...)
 	if err != nil {
-		return createdOrUpdated, errors.Wrapf(err, "unable to create resource of kind: %s, version: %s", gvk.Kind, gvk.Version)
+		return result, errors.Wrapf(err, "unable to create resource of kind: %s, version: %s", gvk.Kind, gvk.Version)
+	}
+	return result, nil
+}
+
+// applyObjectWithRetries applies the given object, and tries again (with a backoff) when the server returned a `Conflict` error,
+// ie, when the object was modified by another party in the meantime. The retries stop as soon as the given context is done.
+// The outcome is recorded in the metrics and as an Event on the owner (if any).
+func (c ApplyClient) applyObjectWithRetries(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (result ApplyResult, err error) {
+	start := time.Now()
+	defer func() {
+		c.recordApply(obj, newApplyObjectConfiguration(options...), result, err, time.Since(start))
+	}()
+	// keep a copy of the given object, to restore it before each new attempt, since it is modified during the apply
+	original := obj.DeepCopyObject()
+	if waitErr := wait.ExponentialBackoffWithContext(ctx, c.conflictBackoff(), func() (bool, error) {
+		result, err = c.applyObject(ctx, obj, options...)
+		if err == nil || !apierrors.IsConflict(err) || IsApplyConflict(err) {
+			return true, nil
+		}
+		log.Info("conflict while applying the object, retrying", "kind", obj.GetObjectKind().GroupVersionKind().Kind, "namespace", obj.GetNamespace(), "name", obj.GetName())
+		reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(original.DeepCopyObject()).Elem())
+		return false, nil
+	}); waitErr != nil && err == nil {
+		// the context was done before the first attempt
+		err = waitErr
 	}
-	return createdOrUpdated, nil
+	return result, err
 }
 
-func (c ApplyClient) applyObject(obj client.Object, options ...ApplyObjectOption) (bool, error) {
-	// gets the meta accessor to the new resource
-	// gets the meta accessor to the new resource
+func (c ApplyClient) conflictBackoff() wait.Backoff {
+	backoff := retry.DefaultRetry
+	if c.conflictRetryBackoff != nil {
+		backoff = *c.conflictRetryBackoff
+	}
+	if backoff.Steps < 1 {
+		// make sure that there's at least one attempt
+		backoff.Steps = 1
+	}
+	return backoff
+}
+
+func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (ApplyResult, error) {
 	config := newApplyObjectConfiguration(options...)
+	result := ApplyResult{
+		Object: obj,
+		GVK:    c.gvkForObject(obj),
+	}
+	var err error
+	if config.serverSideApply {
+		result.Operation, err = c.serverSideApply(ctx, obj, config)
+		return result, err
+	}
 
 	// creates a deepcopy of the new resource to be used to check if it already exists
 	existing := obj.DeepCopyObject().(client.Object)
 
+	var newContent []byte
 	var newConfiguration string
 	if config.saveConfiguration {
 		// set current object as annotation
 		annotations := obj.GetAnnotations()
-		newConfiguration = getNewConfiguration(obj)
+		if newContent, newConfiguration, err = newConfigurationAnnotation(obj, config.configurationEncoding); err != nil {
+			return ApplyResult{}, err
+		}
 		if annotations == nil {
 			annotations = map[string]string{}
 		}
@@ -122,41 +291,83 @@ func (c ApplyClient) applyObject(obj client.Object, options ...ApplyObjectOption
 	}
 	// gets current object (if exists)
 	namespacedName := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
-	if err := c.Client.Get(context.TODO(), namespacedName, existing); err != nil {
+	if err := c.Client.Get(ctx, namespacedName, existing); err != nil {
 		if apierrors.IsNotFound(err) {
-			return true, c.createObj(obj, config.owner)
+			var opts []client.CreateOption
+			if config.dryRun {
+				opts = append(opts, client.DryRunAll)
+			}
+			if err := c.createObj(ctx, obj, config.owner, opts...); err != nil {
+				return ApplyResult{}, err
+			}
+			result.Operation = ApplyCreated
+			return result, nil
 		}
-		return false, errors.Wrapf(err, "unable to get the resource '%v'", existing)
+		return ApplyResult{}, errors.Wrapf(err, "unable to get the resource '%v'", existing)
 	}
 
 	// as it already exists, check using the UpdateStrategy if it should be updated
 	if !config.forceUpdate {
 		existingAnnotations := existing.GetAnnotations()
 		if existingAnnotations != nil {
-			if newConfiguration == existingAnnotations[LastAppliedConfigurationAnnotationKey] {
-				return false, nil
+			if sameConfiguration(newContent, newConfiguration, existingAnnotations[LastAppliedConfigurationAnnotationKey]) {
+				result.Object = existing
+				result.Operation = ApplyUnchanged
+				return result, nil
+			}
+		}
+	}
+
+	// retain the immutable fields which are not set in the new resource (eg, the values allocated or defaulted by the server),
+	// and check if any other immutable field changed, in which case the resource cannot be updated
+	changedFields, err := retainImmutableFields(result.GVK, obj, existing)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+	if len(changedFields) > 0 {
+		if config.recreateOnImmutableChange {
+			if err := c.recreateObject(ctx, obj, existing, config); err != nil {
+				return ApplyResult{}, err
 			}
+			result.Operation = ApplyRecreated
+			return result, nil
 		}
+		log.Info("some immutable fields changed, the resource may not be updated", "kind", result.GVK.Kind, "namespace", obj.GetNamespace(), "name", obj.GetName(), "fields", changedFields)
+	}
+
+	if config.threeWayMerge {
+		// also retain the `spec.ClusterIP` of Services, in case it was part of the last applied configuration
+		if err := RetainClusterIP(obj, existing); err != nil {
+			return ApplyResult{}, err
+		}
+		if result.Operation, err = c.patchObject(ctx, obj, existing, config); err != nil {
+			return ApplyResult{}, err
+		}
+		return result, nil
 	}
 
 	// retrieve the current 'resourceVersion' to set it in the resource passed to the `client.Update()`
 	// otherwise we would get an error with the following message:
 	// `nstemplatetiers.toolchain.dev.openshift.com "base1ns" is invalid: metadata.resourceVersion: Invalid value: 0x0: must be specified for an update`
-	originalGeneration := existing.GetGeneration()
 	obj.SetResourceVersion(existing.GetResourceVersion())
 
 	// also, if the resource to create is a Service and there's a previous version, we should retain its `spec.ClusterIP`, otherwise
 	// the update will fail with the following error:
 	// `Service "<name>" is invalid: spec.clusterIP: Invalid value: "": field is immutable`
 	if err := RetainClusterIP(obj, existing); err != nil {
-		return false, err
+		return ApplyResult{}, err
+	}
+	var opts []client.UpdateOption
+	if config.dryRun {
+		opts = append(opts, client.DryRunAll)
 	}
-	if err := c.Client.Update(context.TODO(), obj); err != nil {
-		return false, errors.Wrapf(err, "unable to update the resource '%v'", obj)
+	if err := c.Client.Update(ctx, obj, opts...); err != nil {
+		return ApplyResult{}, errors.Wrapf(err, "unable to update the resource '%v'", obj)
 	}
 
 	// check if it was changed or not
-	return originalGeneration != obj.GetGeneration(), nil
+	result.Operation = updateOperation(existing, obj)
+	return result, nil
 }
 
 // RetainClusterIP sets the `spec.clusterIP` value from the given 'existing' object
@@ -211,31 +422,115 @@ func marshalObjectContent(newResource runtime.Object) ([]byte, error) {
 	return json.Marshal(newResource)
 }
 
-func (c ApplyClient) createObj(newResource client.Object, owner v1.Object) error {
+func (c ApplyClient) createObj(ctx context.Context, newResource client.Object, owner v1.Object, opts ...client.CreateOption) error {
 	if owner != nil {
 		err := controllerutil.SetControllerReference(owner, newResource, c.Client.Scheme())
 		if err != nil {
 			return errors.Wrap(err, "unable to set controller references")
 		}
 	}
-	return c.Client.Create(context.TODO(), newResource)
+	return c.Client.Create(ctx, newResource, opts...)
 }
 
-// Apply applies the objects, ie, creates or updates them on the cluster
-// returns `true, nil` if at least one of the objects was created or modified,
-// `false, nil` if nothing changed, and `false, err` if an error occurred
-func (c ApplyClient) Apply(toolchainObjects []client.Object, newLabels map[string]string) (bool, error) {
-	createdOrUpdated := false
-	for _, toolchainObject := range toolchainObjects {
-		MergeLabels(toolchainObject, newLabels)
+// Apply applies the objects, ie, creates or updates them on the cluster.
+// The objects are applied by order of their kind priority: Namespaces first, then CustomResourceDefinitions,
+// then ServiceAccounts and RBAC objects, then ConfigMaps and Secrets, and finally all the other objects (eg, the workloads).
+// The objects with the same kind priority are applied concurrently (see `MaxConcurrentApplies`), and a failure
+// does not prevent the other objects from being applied.
+// Returns the result of each given object, at the same index (the objects which could not be applied have the `ApplyFailed` operation,
+// along with their error), and an error listing all the objects which could not be applied (if any)
+func (c ApplyClient) Apply(toolchainObjects []client.Object, newLabels map[string]string) (ApplyResults, error) {
+	return c.ApplyCtx(context.TODO(), toolchainObjects, newLabels)
+}
 
-		result, err := c.ApplyObject(toolchainObject, ForceUpdate(true))
-		if err != nil {
-			return false, errors.Wrapf(err, "unable to create resource of kind: %s, version: %s", toolchainObject.GetObjectKind().GroupVersionKind().Kind, toolchainObject.GetObjectKind().GroupVersionKind().Version)
+// ApplyCtx is the same as Apply, but all the requests to the server are bound to the given context.
+// Once the context is done, the objects which were not applied yet are not applied at all, and are reported as failures.
+func (c ApplyClient) ApplyCtx(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string) (ApplyResults, error) {
+	results := make(ApplyResults, len(toolchainObjects))
+	errs := make([]error, len(toolchainObjects))
+	for _, group := range c.groupByKindPriority(toolchainObjects) {
+		c.applyGroup(ctx, toolchainObjects, group, newLabels, results, errs)
+	}
+	var failures []error
+	for i, toolchainObject := range toolchainObjects {
+		if errs[i] != nil {
+			failures = append(failures, errs[i])
+			results[i] = ApplyResult{
+				Object:    toolchainObject,
+				GVK:       c.gvkForObject(toolchainObject),
+				Operation: ApplyFailed,
+				Err:       errs[i],
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return results, utilerrors.NewAggregate(failures)
+	}
+	return results, nil
+}
+
+// applyGroup applies concurrently the objects at the given indexes, with at most `maxConcurrentApplies` objects at a time.
+// The result or the error of each object is set at the same index in the given `results` and `errs` slices
+func (c ApplyClient) applyGroup(ctx context.Context, toolchainObjects []client.Object, group []int, newLabels map[string]string, results []ApplyResult, errs []error) {
+	maxConcurrentApplies := c.maxConcurrentApplies
+	if maxConcurrentApplies < 1 {
+		maxConcurrentApplies = DefaultMaxConcurrentApplies
+	}
+	semaphore := make(chan struct{}, maxConcurrentApplies)
+	wg := sync.WaitGroup{}
+	wrapErr := func(err error, toolchainObject client.Object) error {
+		gvk := c.gvkForObject(toolchainObject)
+		return errors.Wrapf(err, "unable to create resource of kind: %s, version: %s, namespace: '%s', name: '%s'", gvk.Kind, gvk.Version, toolchainObject.GetNamespace(), toolchainObject.GetName())
+	}
+	for _, i := range group {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = wrapErr(ctx.Err(), toolchainObjects[i])
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			toolchainObject := toolchainObjects[i]
+			MergeLabels(toolchainObject, newLabels)
+			result, err := c.applyObjectWithRetries(ctx, toolchainObject, ForceUpdate(true))
+			if err != nil {
+				errs[i] = wrapErr(err, toolchainObject)
+				return
+			}
+			results[i] = result
+		}(i)
+	}
+	wg.Wait()
+}
+
+// groupByKindPriority groups the indexes of the given objects by the priority of their kind, in increasing order of priority value.
+// The order of the objects within a group is retained.
+func (c ApplyClient) groupByKindPriority(toolchainObjects []client.Object) [][]int {
+	groups := make([][]int, lowestKindPriority+1)
+	for i, toolchainObject := range toolchainObjects {
+		priority := kindPriority(c.gvkForObject(toolchainObject).Kind)
+		groups[priority] = append(groups[priority], i)
+	}
+	nonEmptyGroups := make([][]int, 0, len(groups))
+	for _, group := range groups {
+		if len(group) > 0 {
+			nonEmptyGroups = append(nonEmptyGroups, group)
 		}
-		createdOrUpdated = createdOrUpdated || result
 	}
-	return createdOrUpdated, nil
+	return nonEmptyGroups
+}
+
+// gvkForObject returns the GroupVersionKind of the given object, using the scheme if its `TypeMeta` is not set
+func (c ApplyClient) gvkForObject(obj client.Object) schema.GroupVersionKind {
+	if gvk, err := apiutil.GVKForObject(obj, c.Client.Scheme()); err == nil {
+		return gvk
+	}
+	return obj.GetObjectKind().GroupVersionKind()
 }
 
 // MergeLabels gets current exiting labels and merges them with the new ones provided
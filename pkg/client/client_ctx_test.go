@@ -0,0 +1,177 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	. "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type ctxKey string
+
+func TestApplyWithContext(t *testing.T) {
+	// given
+	addToScheme(t)
+	labels := newLabels("base1ns", "john", "dev")
+	backoff := wait.Backoff{Steps: 3, Duration: time.Millisecond}
+	conflictErr := apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "cm", errors.New("the object has been modified"))
+
+	t.Run("should pass the context to the client", func(t *testing.T) {
+		// given
+		cli := NewFakeClient(t)
+		cl := client.NewApplyClient(cli)
+		ctx := context.WithValue(context.TODO(), ctxKey("reconcile"), "john")
+		var values []interface{}
+		cli.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			values = append(values, ctx.Value(ctxKey("reconcile")))
+			return cli.Client.Get(ctx, key, obj, opts...)
+		}
+		cli.MockCreate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+			values = append(values, ctx.Value(ctxKey("reconcile")))
+			return cli.Client.Create(ctx, obj, opts...)
+		}
+
+		// when
+		result, err := cl.ApplyObjectCtx(ctx, newConfigMap("john-dev", "cm", map[string]string{"param": "value"}))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyCreated, result.Operation)
+		assert.Equal(t, []interface{}{"john", "john"}, values)
+	})
+
+	t.Run("should not apply when context is cancelled", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+
+		// when
+		_, err := cl.ApplyObjectCtx(ctx, newConfigMap("john-dev", "cm", map[string]string{"param": "value"}))
+
+		// then
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.Canceled))
+		err = cli.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "cm"}, &corev1.ConfigMap{})
+		assert.True(t, apierrors.IsNotFound(err))
+
+		t.Run("and report all objects as failures", func(t *testing.T) {
+			// when
+			results, err := cl.ApplyCtx(ctx, []runtimeclient.Object{newConfigMap("john-dev", "cm-1", map[string]string{"param": "value"}), newConfigMap("john-dev", "cm-2", map[string]string{"param": "value"})}, labels)
+
+			// then
+			require.Error(t, err)
+			require.Len(t, results, 2)
+			assert.Len(t, results.Failed(), 2)
+			assert.False(t, results.CreatedOrUpdated())
+			assert.Contains(t, err.Error(), "name: 'cm-1': context canceled")
+			assert.Contains(t, err.Error(), "name: 'cm-2': context canceled")
+		})
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+
+		t.Run("should retry when update failed with conflict", func(t *testing.T) {
+			// given
+			cli := NewFakeClient(t)
+			cl := client.NewApplyClient(cli, client.ConflictRetryBackoff(backoff))
+			_, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}))
+			require.NoError(t, err)
+			attempts := 0
+			cli.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				attempts++
+				if attempts == 1 {
+					return conflictErr
+				}
+				return cli.Client.Update(ctx, obj, opts...)
+			}
+			cm := newConfigMap("john-dev", "cm", map[string]string{"param": "other-value"})
+
+			// when
+			result, err := cl.ApplyObjectCtx(context.TODO(), cm)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 2, attempts)
+			assert.Equal(t, client.ApplyUpdated, result.Operation)
+			// the given object was restored before the second attempt, so the last applied configuration is not nested
+			assert.NotContains(t, cm.Annotations[client.LastAppliedConfigurationAnnotationKey], client.LastAppliedConfigurationAnnotationKey)
+		})
+
+		t.Run("should fail when conflicts persist", func(t *testing.T) {
+			// given
+			cli := NewFakeClient(t)
+			cl := client.NewApplyClient(cli, client.ConflictRetryBackoff(backoff))
+			_, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}))
+			require.NoError(t, err)
+			attempts := 0
+			cli.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				attempts++
+				return conflictErr
+			}
+
+			// when
+			_, err = cl.ApplyObjectCtx(context.TODO(), newConfigMap("john-dev", "cm", map[string]string{"param": "other-value"}))
+
+			// then
+			require.Error(t, err)
+			assert.True(t, apierrors.IsConflict(err))
+			assert.Equal(t, 3, attempts)
+		})
+
+		t.Run("should not retry when other error occurred", func(t *testing.T) {
+			// given
+			cli := NewFakeClient(t)
+			cl := client.NewApplyClient(cli, client.ConflictRetryBackoff(backoff))
+			_, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}))
+			require.NoError(t, err)
+			attempts := 0
+			cli.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				attempts++
+				return errors.New("mock error")
+			}
+
+			// when
+			_, err = cl.ApplyObjectCtx(context.TODO(), newConfigMap("john-dev", "cm", map[string]string{"param": "other-value"}))
+
+			// then
+			require.Error(t, err)
+			assert.Equal(t, 1, attempts)
+		})
+
+		t.Run("should stop retrying when context is done", func(t *testing.T) {
+			// given
+			cli := NewFakeClient(t)
+			cl := client.NewApplyClient(cli, client.ConflictRetryBackoff(wait.Backoff{Steps: 10, Duration: time.Second}))
+			_, err := cl.ApplyObject(newConfigMap("john-dev", "cm", map[string]string{"param": "value"}))
+			require.NoError(t, err)
+			ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+			defer cancel()
+			attempts := 0
+			cli.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				attempts++
+				return conflictErr
+			}
+
+			// when
+			start := time.Now()
+			_, err = cl.ApplyObjectCtx(ctx, newConfigMap("john-dev", "cm", map[string]string{"param": "other-value"}))
+
+			// then
+			require.Error(t, err)
+			assert.Equal(t, 1, attempts)
+			assert.Less(t, time.Since(start), time.Second)
+		})
+	})
+}
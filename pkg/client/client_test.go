@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -21,6 +23,7 @@ import (
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -84,11 +87,11 @@ func TestApplySingle(t *testing.T) {
 					originalGeneration := obj.GetGeneration()
 
 					// when updating with the same obj again
-					createdOrChanged, err := cl.ApplyObject(obj, client.ForceUpdate(true))
+					result, err := cl.ApplyObject(obj, client.ForceUpdate(true))
 
 					// then
 					require.NoError(t, err)
-					assert.False(t, createdOrChanged) // resource was not updated on the server, so returned value is `false`
+					assert.False(t, result.CreatedOrUpdated()) // resource was not updated on the server, so returned value is `false`
 					updateGeneration := obj.GetGeneration()
 					assert.Equal(t, originalGeneration, updateGeneration)
 				})
@@ -102,11 +105,11 @@ func TestApplySingle(t *testing.T) {
 					originalGeneration := obj.GetGeneration()
 					obj.Spec.ClusterIP = "" // modify for version to update
 					// when updating with the same obj again
-					createdOrChanged, err := cl.ApplyObject(obj, client.ForceUpdate(true))
+					result, err := cl.ApplyObject(obj, client.ForceUpdate(true))
 
 					// then
 					require.NoError(t, err)
-					assert.False(t, createdOrChanged) // resource was not updated on the server, so returned value is `false`
+					assert.False(t, result.CreatedOrUpdated()) // resource was not updated on the server, so returned value is `false`
 					updateGeneration := obj.GetGeneration()
 					assert.Equal(t, originalGeneration, updateGeneration)
 					assert.Equal(t, defaultService.Spec.ClusterIP, obj.Spec.ClusterIP)
@@ -123,11 +126,11 @@ func TestApplySingle(t *testing.T) {
 					// when updating with the modified obj
 					modifiedObj := modifiedService.DeepCopy()
 					modifiedObj.Spec.ClusterIP = ""
-					createdOrChanged, err := cl.ApplyObject(modifiedObj, client.ForceUpdate(true))
+					result, err := cl.ApplyObject(modifiedObj, client.ForceUpdate(true))
 
 					// then
 					require.NoError(t, err)
-					assert.True(t, createdOrChanged) // resource was updated on the server, so returned value if `true`
+					assert.True(t, result.CreatedOrUpdated()) // resource was updated on the server, so returned value if `true`
 					updateGeneration := modifiedObj.GetGeneration()
 					assert.Equal(t, originalGeneration+1, updateGeneration)
 					assert.NotEmpty(t, modifiedObj.Annotations[client.LastAppliedConfigurationAnnotationKey])
@@ -144,11 +147,11 @@ func TestApplySingle(t *testing.T) {
 					// when updating with the modified obj
 					modifiedObj := modifiedService.DeepCopy()
 					modifiedObj.Spec.ClusterIP = ""
-					createdOrChanged, err := cl.ApplyObject(modifiedObj, client.ForceUpdate(true))
+					result, err := cl.ApplyObject(modifiedObj, client.ForceUpdate(true))
 
 					// then
 					require.NoError(t, err)
-					assert.True(t, createdOrChanged) // resource was updated on the server, so returned value if `true`
+					assert.True(t, result.CreatedOrUpdated()) // resource was updated on the server, so returned value if `true`
 					updateGeneration := modifiedObj.GetGeneration()
 					assert.Equal(t, originalGeneration+1, updateGeneration)
 					assert.Equal(t, defaultService.Spec.ClusterIP, obj.Spec.ClusterIP)
@@ -159,11 +162,11 @@ func TestApplySingle(t *testing.T) {
 					cl, cli := newClient(t)
 
 					// when
-					createdOrChanged, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject(), client.ForceUpdate(true), client.SetOwner(&appsv1.Deployment{}))
+					result, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject(), client.ForceUpdate(true), client.SetOwner(&appsv1.Deployment{}))
 
 					// then
 					require.NoError(t, err)
-					assert.True(t, createdOrChanged)
+					assert.True(t, result.CreatedOrUpdated())
 					service := &corev1.Service{}
 					err = cli.Get(context.TODO(), namespacedName, service)
 					require.NoError(t, err)
@@ -182,11 +185,11 @@ func TestApplySingle(t *testing.T) {
 					require.NoError(t, err)
 
 					// when
-					createdOrChanged, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject())
+					result, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject())
 
 					// then
 					require.NoError(t, err)
-					assert.True(t, createdOrChanged)
+					assert.True(t, result.CreatedOrUpdated())
 					service := &corev1.Service{}
 					err = cli.Get(context.TODO(), namespacedName, service)
 					require.NoError(t, err)
@@ -200,11 +203,11 @@ func TestApplySingle(t *testing.T) {
 					require.NoError(t, err)
 
 					// when
-					createdOrChanged, err := cl.ApplyRuntimeObject(defaultService.DeepCopyObject())
+					result, err := cl.ApplyRuntimeObject(defaultService.DeepCopyObject())
 
 					// then
 					require.NoError(t, err)
-					assert.False(t, createdOrChanged)
+					assert.False(t, result.CreatedOrUpdated())
 				})
 
 				t.Run("when object is missing, it should create it", func(t *testing.T) {
@@ -212,11 +215,11 @@ func TestApplySingle(t *testing.T) {
 					cl, cli := newClient(t)
 
 					// when
-					createdOrChanged, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject(), client.SetOwner(&appsv1.Deployment{}))
+					result, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject(), client.SetOwner(&appsv1.Deployment{}))
 
 					// then
 					require.NoError(t, err)
-					assert.True(t, createdOrChanged)
+					assert.True(t, result.CreatedOrUpdated())
 					service := &corev1.Service{}
 					err = cli.Get(context.TODO(), namespacedName, service)
 					require.NoError(t, err)
@@ -232,11 +235,11 @@ func TestApplySingle(t *testing.T) {
 					cl, cli := newClient(t)
 
 					// when
-					createdOrChanged, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject(), client.SaveConfiguration(false))
+					result, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject(), client.SaveConfiguration(false))
 
 					// then
 					require.NoError(t, err)
-					assert.True(t, createdOrChanged)
+					assert.True(t, result.CreatedOrUpdated())
 					service := &corev1.Service{}
 					err = cli.Get(context.TODO(), namespacedName, service)
 					require.NoError(t, err)
@@ -251,11 +254,11 @@ func TestApplySingle(t *testing.T) {
 					require.NoError(t, err)
 
 					// when
-					createdOrChanged, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject(), client.SaveConfiguration(false))
+					result, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject(), client.SaveConfiguration(false))
 
 					// then
 					require.NoError(t, err)
-					assert.True(t, createdOrChanged)
+					assert.True(t, result.CreatedOrUpdated())
 					service := &corev1.Service{}
 					err = cli.Get(context.TODO(), namespacedName, service)
 					require.NoError(t, err)
@@ -272,11 +275,11 @@ func TestApplySingle(t *testing.T) {
 				}
 
 				// when
-				createdOrChanged, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject())
+				result, err := cl.ApplyRuntimeObject(modifiedService.DeepCopyObject())
 
 				// then
 				require.Error(t, err)
-				assert.False(t, createdOrChanged)
+				assert.False(t, result.CreatedOrUpdated())
 				assert.Contains(t, err.Error(), "unable to get the resource")
 			})
 		})
@@ -302,11 +305,11 @@ func TestApplySingle(t *testing.T) {
 					require.NoError(t, err)
 
 					// when updating with the same obj again
-					createdOrChanged, err := cl.ApplyObject(modifiedObj, client.ForceUpdate(true))
+					result, err := cl.ApplyObject(modifiedObj, client.ForceUpdate(true))
 
 					// then
 					require.NoError(t, err)
-					assert.False(t, createdOrChanged) // resource was not updated on the server, so returned value is `false`
+					assert.False(t, result.CreatedOrUpdated()) // resource was not updated on the server, so returned value is `false`
 					assert.Equal(t, obj.GetGeneration(), modifiedObj.GetGeneration())
 					clusterIP, found, err := unstructured.NestedString(modifiedObj.Object, "spec", "clusterIP")
 					require.NoError(t, err)
@@ -326,11 +329,11 @@ func TestApplySingle(t *testing.T) {
 			require.NoError(t, err)
 
 			// when
-			createdOrChanged, err := cl.ApplyRuntimeObject(modifiedCm.DeepCopyObject())
+			result, err := cl.ApplyRuntimeObject(modifiedCm.DeepCopyObject())
 
 			// then
 			require.NoError(t, err)
-			assert.True(t, createdOrChanged)
+			assert.True(t, result.CreatedOrUpdated())
 			configMap := &corev1.ConfigMap{}
 			namespacedName := types.NamespacedName{Namespace: "toolchain-host-operator", Name: "registration-service"}
 			err = cli.Get(context.TODO(), namespacedName, configMap)
@@ -533,11 +536,11 @@ func TestProcessAndApply(t *testing.T) {
 		labels := newLabels("", "john", "")
 
 		// when
-		createdOrUpdated, err := client.NewApplyClient(cl).Apply(objs, labels)
+		results, err := client.NewApplyClient(cl).Apply(objs, labels)
 
 		// then
 		require.NoError(t, err)
-		assert.True(t, createdOrUpdated)
+		assert.True(t, results.CreatedOrUpdated())
 		assertNamespaceExists(t, cl, user, labels, commit)
 	})
 
@@ -553,11 +556,11 @@ func TestProcessAndApply(t *testing.T) {
 		labels := newLabels("base1ns", "john", "dev")
 
 		// when
-		createdOrUpdated, err := client.NewApplyClient(cl).Apply(objs, labels)
+		results, err := client.NewApplyClient(cl).Apply(objs, labels)
 
 		// then
 		require.NoError(t, err)
-		assert.True(t, createdOrUpdated)
+		assert.True(t, results.CreatedOrUpdated())
 		assertRoleBindingExists(t, cl, user, labels)
 	})
 
@@ -573,11 +576,11 @@ func TestProcessAndApply(t *testing.T) {
 		labels := newLabels("", "john", "dev")
 
 		// when
-		createdOrUpdated, err := client.NewApplyClient(cl).Apply(objs, labels)
+		results, err := client.NewApplyClient(cl).Apply(objs, labels)
 
 		// then
 		require.NoError(t, err)
-		assert.True(t, createdOrUpdated)
+		assert.True(t, results.CreatedOrUpdated())
 		assertNamespaceExists(t, cl, user, labels, commit)
 		assertRoleBindingExists(t, cl, user, labels)
 	})
@@ -600,9 +603,9 @@ func TestProcessAndApply(t *testing.T) {
 		require.NoError(t, err)
 		witoutType := newLabels("base1ns", "john", "")
 
-		createdOrUpdated, err := client.NewApplyClient(cl).Apply(objs, witoutType)
+		results, err := client.NewApplyClient(cl).Apply(objs, witoutType)
 		require.NoError(t, err)
-		assert.True(t, createdOrUpdated)
+		assert.True(t, results.CreatedOrUpdated())
 		assertRoleBindingExists(t, cl, user, witoutType)
 
 		// when rolebinding changes
@@ -612,11 +615,11 @@ func TestProcessAndApply(t *testing.T) {
 		objs, err = p.Process(tmpl, values)
 		require.NoError(t, err)
 		complete := newLabels("advanced", "john", "dev")
-		createdOrUpdated, err = client.NewApplyClient(cl).Apply(objs, complete)
+		results, err = client.NewApplyClient(cl).Apply(objs, complete)
 
 		// then
 		require.NoError(t, err)
-		assert.True(t, createdOrUpdated)
+		assert.True(t, results.CreatedOrUpdated())
 		binding := assertRoleBindingExists(t, cl, user, complete)
 		require.Len(t, binding.Subjects, 2)
 		assert.Equal(t, "User", binding.Subjects[0].Kind)
@@ -635,18 +638,18 @@ func TestProcessAndApply(t *testing.T) {
 		objs, err := p.Process(tmpl, values)
 		require.NoError(t, err)
 		labels := newLabels("base1ns", "john", "dev")
-		created, err := client.NewApplyClient(cl).Apply(objs, labels)
+		results, err := client.NewApplyClient(cl).Apply(objs, labels)
 		require.NoError(t, err)
-		assert.True(t, created)
+		assert.True(t, results.CreatedOrUpdated())
 		assertNamespaceExists(t, cl, user, labels, commit)
 		assertRoleBindingExists(t, cl, user, labels)
 
 		// when apply the same template again
-		updated, err := client.NewApplyClient(cl).Apply(objs, labels)
+		results, err = client.NewApplyClient(cl).Apply(objs, labels)
 
 		// then
 		require.NoError(t, err)
-		assert.False(t, updated)
+		assert.False(t, results.CreatedOrUpdated())
 	})
 
 	t.Run("failures", func(t *testing.T) {
@@ -665,11 +668,11 @@ func TestProcessAndApply(t *testing.T) {
 			// when
 			objs, err := p.Process(tmpl, values)
 			require.NoError(t, err)
-			createdOrUpdated, err := client.NewApplyClient(cl).Apply(objs, newLabels("", "", ""))
+			results, err := client.NewApplyClient(cl).Apply(objs, newLabels("", "", ""))
 
 			// then
 			require.Error(t, err)
-			assert.False(t, createdOrUpdated)
+			assert.False(t, results.CreatedOrUpdated())
 		})
 
 		t.Run("should fail to update template object", func(t *testing.T) {
@@ -685,9 +688,9 @@ func TestProcessAndApply(t *testing.T) {
 			objs, err := p.Process(tmpl, values)
 			require.NoError(t, err)
 			labels := newLabels("", "", "")
-			createdOrUpdated, err := client.NewApplyClient(cl).Apply(objs, labels)
+			results, err := client.NewApplyClient(cl).Apply(objs, labels)
 			require.NoError(t, err)
-			assert.True(t, createdOrUpdated)
+			assert.True(t, results.CreatedOrUpdated())
 
 			// when
 			tmpl, err = DecodeTemplate(decoder,
@@ -695,11 +698,11 @@ func TestProcessAndApply(t *testing.T) {
 			require.NoError(t, err)
 			objs, err = p.Process(tmpl, values)
 			require.NoError(t, err)
-			createdOrUpdated, err = client.NewApplyClient(cl).Apply(objs, newLabels("advanced", "john", "dev"))
+			results, err = client.NewApplyClient(cl).Apply(objs, newLabels("advanced", "john", "dev"))
 
 			// then
 			assert.Error(t, err)
-			assert.False(t, createdOrUpdated)
+			assert.False(t, results.CreatedOrUpdated())
 		})
 	})
 
@@ -727,11 +730,11 @@ func TestProcessAndApply(t *testing.T) {
 			},
 		})
 		labels := newLabels("base1ns", "john", "dev")
-		createdOrUpdated, err := client.NewApplyClient(cl).Apply(objs, labels)
+		results, err := client.NewApplyClient(cl).Apply(objs, labels)
 
 		// then
 		require.NoError(t, err)
-		assert.True(t, createdOrUpdated)
+		assert.True(t, results.CreatedOrUpdated())
 		ns := assertNamespaceExists(t, cl, user, labels, commit)
 		// verify owner refs
 		assert.Equal(t, []metav1.OwnerReference{
@@ -744,6 +747,126 @@ func TestProcessAndApply(t *testing.T) {
 	})
 }
 
+func TestApplyOrderAndConcurrency(t *testing.T) {
+	// given
+	addToScheme(t)
+	labels := newLabels("base1ns", "john", "dev")
+	newObjects := func() []runtimeclient.Object {
+		return []runtimeclient.Object{
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "deployment", Namespace: "john-dev"}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-1", Namespace: "john-dev"}},
+			&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "john-dev"}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-2", Namespace: "john-dev"}},
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "john-dev"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "john-dev"}},
+		}
+	}
+	assertExists := func(t *testing.T, cl runtimeclient.Client, namespace, name string, obj runtimeclient.Object) {
+		err := cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, obj)
+		require.NoError(t, err)
+		assert.Equal(t, labels, obj.GetLabels())
+	}
+
+	t.Run("should apply objects by order of kind priority", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t)
+		var created []string
+		lock := sync.Mutex{}
+		cl.MockCreate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+			lock.Lock()
+			created = append(created, obj.GetName())
+			lock.Unlock()
+			return cl.Client.Create(ctx, obj, opts...)
+		}
+
+		// when
+		results, err := client.NewApplyClient(cl).Apply(newObjects(), labels)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, results.CreatedOrUpdated())
+		require.Len(t, created, 6)
+		assert.Equal(t, "john-dev", created[0])
+		assert.Equal(t, "sa", created[1])
+		assert.ElementsMatch(t, []string{"cm-1", "cm-2", "secret"}, created[2:5])
+		assert.Equal(t, "deployment", created[5])
+	})
+
+	t.Run("should not apply more objects concurrently than allowed", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t)
+		var current, max int32
+		cl.MockCreate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+			c := atomic.AddInt32(&current, 1)
+			defer atomic.AddInt32(&current, -1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if c <= m || atomic.CompareAndSwapInt32(&max, m, c) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return cl.Client.Create(ctx, obj, opts...)
+		}
+		objs := make([]runtimeclient.Object, 10)
+		for i := range objs {
+			objs[i] = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("cm-%d", i), Namespace: "john-dev"}}
+		}
+
+		// when
+		results, err := client.NewApplyClient(cl, client.MaxConcurrentApplies(2)).Apply(objs, labels)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, results.CreatedOrUpdated())
+		assert.LessOrEqual(t, atomic.LoadInt32(&max), int32(2))
+		for _, obj := range objs {
+			assertExists(t, cl, obj.GetNamespace(), obj.GetName(), &corev1.ConfigMap{})
+		}
+	})
+
+	t.Run("should apply all objects and return all the failures", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t)
+		cl.MockCreate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+			if obj.GetName() == "cm-1" || obj.GetName() == "sa" {
+				return fmt.Errorf("mock error for '%s'", obj.GetName())
+			}
+			return cl.Client.Create(ctx, obj, opts...)
+		}
+
+		// when
+		results, err := client.NewApplyClient(cl).Apply(newObjects(), labels)
+
+		// then
+		require.Error(t, err)
+		// the result of each object is returned, at the same index as the given object
+		require.Len(t, results, 6)
+		for i, name := range []string{"deployment", "cm-1", "sa", "cm-2", "secret", "john-dev"} {
+			assert.Equal(t, name, results[i].Object.GetName())
+		}
+		for _, i := range []int{0, 3, 4, 5} {
+			assert.Equal(t, client.ApplyCreated, results[i].Operation)
+			assert.NoError(t, results[i].Err)
+		}
+		failed := results.Failed()
+		require.Len(t, failed, 2)
+		assert.Equal(t, "ConfigMap", failed[0].GVK.Kind)
+		assert.Equal(t, "john-dev", failed[0].Object.GetNamespace())
+		assert.Equal(t, "cm-1", failed[0].Object.GetName())
+		assert.EqualError(t, failed[0].Err, "unable to create resource of kind: ConfigMap, version: v1, namespace: 'john-dev', name: 'cm-1': mock error for 'cm-1'")
+		assert.Equal(t, "ServiceAccount", failed[1].GVK.Kind)
+		assert.Equal(t, client.ApplyFailed, failed[1].Operation)
+		assert.False(t, failed[1].CreatedOrUpdated())
+		assert.Contains(t, err.Error(), "unable to create resource of kind: ServiceAccount, version: v1, namespace: 'john-dev', name: 'sa': mock error for 'sa'")
+		assert.Contains(t, err.Error(), "unable to create resource of kind: ConfigMap, version: v1, namespace: 'john-dev', name: 'cm-1': mock error for 'cm-1'")
+		assertExists(t, cl, "", "john-dev", &corev1.Namespace{})
+		assertExists(t, cl, "john-dev", "cm-2", &corev1.ConfigMap{})
+		assertExists(t, cl, "john-dev", "secret", &corev1.Secret{})
+		assertExists(t, cl, "john-dev", "deployment", &appsv1.Deployment{})
+	})
+}
+
 func TestMergeLabels(t *testing.T) {
 	// given
 	additionalLabel := map[string]string{
@@ -872,8 +995,8 @@ func getNameWithTimestamp(prefix string) string {
 	return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
 }
 
-func newClient(t *testing.T) (*client.ApplyClient, *FakeClient) {
-	cl := NewFakeClient(t)
+func newClient(t *testing.T, initObjs ...runtime.Object) (*client.ApplyClient, *FakeClient) {
+	cl := NewFakeClient(t, initObjs...)
 	return client.NewApplyClient(cl), cl
 }
 
@@ -887,3 +1010,93 @@ func addToScheme(t *testing.T) *runtime.Scheme {
 	require.NoError(t, err)
 	return s
 }
+
+// configMapOption an option to configure the ConfigMaps returned by `newConfigMap`
+type configMapOption func(*corev1.ConfigMap)
+
+func withLabels(labels map[string]string) configMapOption {
+	return func(cm *corev1.ConfigMap) {
+		cm.Labels = labels
+	}
+}
+
+func withFinalizers(finalizers ...string) configMapOption {
+	return func(cm *corev1.ConfigMap) {
+		cm.Finalizers = finalizers
+	}
+}
+
+func newConfigMap(namespace, name string, data map[string]string, options ...configMapOption) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Data: data,
+	}
+	for _, configure := range options {
+		configure(cm)
+	}
+	return cm
+}
+
+// mockServerSideApply records the options of the server-side apply requests of ConfigMaps sent to the server by the given client.
+// Since the fake client does not support the server-side apply, the ConfigMaps are created or updated instead.
+func mockServerSideApply(t *testing.T, cli *FakeClient) *runtimeclient.PatchOptions {
+	patchOptions := &runtimeclient.PatchOptions{}
+	cli.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+		require.Equal(t, types.ApplyPatchType, patch.Type())
+		*patchOptions = runtimeclient.PatchOptions{}
+		patchOptions.ApplyOptions(opts)
+		// the apiVersion and kind must be set in the request body
+		assert.Equal(t, "ConfigMap", obj.GetObjectKind().GroupVersionKind().Kind)
+		assert.Equal(t, "v1", obj.GetObjectKind().GroupVersionKind().Version)
+		assert.Empty(t, obj.GetResourceVersion())
+		existing := &corev1.ConfigMap{}
+		if err := cli.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(obj), existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				return Create(ctx, cli, obj)
+			}
+			return err
+		}
+		obj.SetResourceVersion(existing.GetResourceVersion())
+		return Update(ctx, cli, obj)
+	}
+	return patchOptions
+}
+
+// recordPatchTypes records the type of the patches sent to the server by the given client
+func recordPatchTypes(cli *FakeClient) *[]types.PatchType {
+	patchTypes := &[]types.PatchType{}
+	cli.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+		*patchTypes = append(*patchTypes, patch.Type())
+		return cli.Client.Patch(ctx, obj, patch, opts...)
+	}
+	return patchTypes
+}
+
+// newClientWithAppliedObjects returns an ApplyClient with copies of the given objects which were already applied with the given labels
+func newClientWithAppliedObjects(t *testing.T, labels map[string]string, applied ...runtimeclient.Object) (*client.ApplyClient, *FakeClient) {
+	cl, cli := newClient(t)
+	_, err := cl.Apply(copyObjects(applied), labels)
+	require.NoError(t, err)
+	return cl, cli
+}
+
+func copyObjects(objs []runtimeclient.Object) []runtimeclient.Object {
+	copies := make([]runtimeclient.Object, len(objs))
+	for i, obj := range objs {
+		copies[i] = obj.DeepCopyObject().(runtimeclient.Object)
+	}
+	return copies
+}
+
+// newClientWithInventory returns an ApplyClient with copies of the given objects which were already applied with the given labels
+// and recorded in the given inventory
+func newClientWithInventory(t *testing.T, labels map[string]string, inventory client.Inventory, applied ...runtimeclient.Object) (*client.ApplyClient, *FakeClient) {
+	cl, cli := newClient(t)
+	results, _, err := cl.ApplyWithPrune(copyObjects(applied), labels, inventory)
+	require.NoError(t, err)
+	require.True(t, results.CreatedOrUpdated())
+	return cl, cli
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// DefaultDeletePollInterval the default interval between two checks that the deleted objects are gone
+const DefaultDeletePollInterval = 500 * time.Millisecond
+
+// DeleteOption an option when deleting objects with `DeleteAndWait` or `DeleteBySelectorAndWait`
+type DeleteOption func(config *deleteConfiguration)
+
+type deleteConfiguration struct {
+	propagationPolicy metav1.DeletionPropagation
+	waitForDeletion   bool
+	pollInterval      time.Duration
+}
+
+func newDeleteConfiguration(options ...DeleteOption) deleteConfiguration {
+	config := deleteConfiguration{
+		propagationPolicy: metav1.DeletePropagationBackground,
+		waitForDeletion:   true,
+		pollInterval:      DefaultDeletePollInterval,
+	}
+	for _, opt := range options {
+		opt(&config)
+	}
+	return config
+}
+
+// DeletePropagation sets the propagation policy of the deletion (default: `Background`)
+func DeletePropagation(policy metav1.DeletionPropagation) DeleteOption {
+	return func(config *deleteConfiguration) {
+		config.propagationPolicy = policy
+	}
+}
+
+// WaitForDeletion if `true`, then the deletion blocks until all the objects are gone or the context is done.
+// Otherwise, the objects which still exist right after the deletion are reported without waiting,
+// which is convenient for reconcilers which can requeue the request (default: `true`)
+func WaitForDeletion(waitForDeletion bool) DeleteOption {
+	return func(config *deleteConfiguration) {
+		config.waitForDeletion = waitForDeletion
+	}
+}
+
+// DeletePollInterval sets the interval between two checks that the deleted objects are gone (default: `DefaultDeletePollInterval`)
+func DeletePollInterval(interval time.Duration) DeleteOption {
+	return func(config *deleteConfiguration) {
+		config.pollInterval = interval
+	}
+}
+
+// RemainingObject an object which was deleted but still exists, typically because some finalizers were not removed yet
+type RemainingObject struct {
+	GVK            schema.GroupVersionKind
+	NamespacedName types.NamespacedName
+	// Finalizers the finalizers which are blocking the deletion of the object
+	Finalizers []string
+}
+
+func (o RemainingObject) String() string {
+	if len(o.Finalizers) == 0 {
+		return fmt.Sprintf("%s '%s'", o.GVK.Kind, o.NamespacedName)
+	}
+	return fmt.Sprintf("%s '%s' (finalizers: %s)", o.GVK.Kind, o.NamespacedName, strings.Join(o.Finalizers, ", "))
+}
+
+// DeleteAndWait deletes the given objects (the ones which are already gone are ignored) and waits until they are all gone.
+// Returns the objects which still exist, along with an error listing them and their finalizers if the context is done before
+// they are all gone (the objects which cannot be checked are checked again in the next round). With `WaitForDeletion(false)`, the objects which still exist right after the deletion are returned without error.
+func DeleteAndWait(ctx context.Context, cl client.Client, objs []client.Object, options ...DeleteOption) ([]RemainingObject, error) {
+	config := newDeleteConfiguration(options...)
+	for _, obj := range objs {
+		if err := cl.Delete(ctx, obj, client.PropagationPolicy(config.propagationPolicy)); err != nil && !apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "unable to delete the resource '%s/%s'", obj.GetNamespace(), obj.GetName())
+		}
+	}
+	if !config.waitForDeletion {
+		remaining, _, err := getRemainingObjects(ctx, cl, objs)
+		return remaining, err
+	}
+
+	var remaining []RemainingObject
+	if err := wait.PollImmediateUntilWithContext(ctx, config.pollInterval, func(ctx context.Context) (bool, error) {
+		stillRemaining, remainingObjs, err := getRemainingObjects(ctx, cl, objs)
+		if err != nil {
+			// keep the last known remaining objects and check them again in the next round, so that only the context stops the wait
+			log.Error(err, "unable to check the deleted resources")
+			return false, nil
+		}
+		// only check the objects which still exist in the next round
+		remaining, objs = stillRemaining, remainingObjs
+		return len(remaining) == 0, nil
+	}); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		blocking := make([]string, len(remaining))
+		for i, o := range remaining {
+			blocking[i] = o.String()
+		}
+		return remaining, errors.Wrapf(err, "the deletion of the following resources did not complete: %s", strings.Join(blocking, ", "))
+	}
+	return nil, nil
+}
+
+// DeleteBySelectorAndWait deletes the objects of the type of the given list which match the given selector in the given namespace
+// (or in all namespaces if empty), and waits until they are all gone (see `DeleteAndWait`)
+func DeleteBySelectorAndWait(ctx context.Context, cl client.Client, list client.ObjectList, namespace string, selector labels.Selector, options ...DeleteOption) ([]RemainingObject, error) {
+	if err := cl.List(ctx, list, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.Wrapf(err, "unable to list the resources matching the selector '%s'", selector)
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list the resources matching the selector '%s'", selector)
+	}
+	objs := make([]client.Object, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("unable to cast of the object to client.Object: %+v", item)
+		}
+		objs = append(objs, obj)
+	}
+	return DeleteAndWait(ctx, cl, objs, options...)
+}
+
+// getRemainingObjects returns the given objects which still exist (and have the same UID, if known),
+// both as reports and as a subset of the given objects
+func getRemainingObjects(ctx context.Context, cl client.Client, objs []client.Object) ([]RemainingObject, []client.Object, error) {
+	var remaining []RemainingObject
+	var remainingObjs []client.Object
+	for _, obj := range objs {
+		gvk, err := apiutil.GVKForObject(obj, cl.Scheme())
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to get the GroupVersionKind of the resource '%s/%s'", obj.GetNamespace(), obj.GetName())
+		}
+		existing := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(client.Object)
+		existing.GetObjectKind().SetGroupVersionKind(gvk)
+		if err := cl.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, nil, errors.Wrapf(err, "unable to get the resource '%s/%s'", obj.GetNamespace(), obj.GetName())
+		}
+		if obj.GetUID() != "" && existing.GetUID() != obj.GetUID() {
+			// the object was deleted and created again
+			continue
+		}
+		remaining = append(remaining, RemainingObject{
+			GVK:            gvk,
+			NamespacedName: client.ObjectKeyFromObject(obj),
+			Finalizers:     existing.GetFinalizers(),
+		})
+		remainingObjs = append(remainingObjs, obj)
+	}
+	return remaining, remainingObjs, nil
+}
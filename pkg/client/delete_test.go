@@ -0,0 +1,234 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	. "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDeleteAndWait(t *testing.T) {
+	// given
+	addToScheme(t)
+	ownerLabels := map[string]string{"toolchain.dev.openshift.com/owner": "john"}
+	exists := func(t *testing.T, cl runtimeclient.Client, name string) bool {
+		err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: name}, &corev1.ConfigMap{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		require.NoError(t, err)
+		return true
+	}
+
+	t.Run("should delete objects and wait until they are gone", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t, newConfigMap("john-dev", "first", nil, withLabels(ownerLabels)), newConfigMap("john-dev", "second", nil, withLabels(ownerLabels)))
+		var propagationPolicies []metav1.DeletionPropagation
+		cl.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+			deleteOpts := &runtimeclient.DeleteOptions{}
+			deleteOpts.ApplyOptions(opts)
+			propagationPolicies = append(propagationPolicies, *deleteOpts.PropagationPolicy)
+			return cl.Client.Delete(ctx, obj, opts...)
+		}
+
+		// when
+		remaining, err := client.DeleteAndWait(context.TODO(), cl,
+			[]runtimeclient.Object{newConfigMap("john-dev", "first", nil, withLabels(ownerLabels)), newConfigMap("john-dev", "second", nil, withLabels(ownerLabels)), newConfigMap("john-dev", "unknown", nil, withLabels(ownerLabels))},
+			client.DeletePropagation(metav1.DeletePropagationForeground))
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, remaining)
+		assert.False(t, exists(t, cl, "first"))
+		assert.False(t, exists(t, cl, "second"))
+		assert.Equal(t, []metav1.DeletionPropagation{
+			metav1.DeletePropagationForeground, metav1.DeletePropagationForeground, metav1.DeletePropagationForeground,
+		}, propagationPolicies)
+	})
+
+	t.Run("should wait until finalizers are removed", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t, newConfigMap("john-dev", "first", nil, withLabels(ownerLabels), withFinalizers("finalizer.toolchain.dev.openshift.com")))
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			cm := &corev1.ConfigMap{}
+			if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "first"}, cm); err != nil {
+				return
+			}
+			cm.Finalizers = nil
+			_ = cl.Update(context.TODO(), cm)
+		}()
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+
+		// when
+		remaining, err := client.DeleteAndWait(ctx, cl, []runtimeclient.Object{newConfigMap("john-dev", "first", nil, withLabels(ownerLabels))}, client.DeletePollInterval(10*time.Millisecond))
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, remaining)
+		assert.False(t, exists(t, cl, "first"))
+	})
+
+	t.Run("should report blocking objects when context expires", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t, newConfigMap("john-dev", "first", nil, withLabels(ownerLabels), withFinalizers("finalizer.toolchain.dev.openshift.com", "other.toolchain.dev.openshift.com")), newConfigMap("john-dev", "second", nil, withLabels(ownerLabels)))
+		ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+		defer cancel()
+
+		// when
+		remaining, err := client.DeleteAndWait(ctx, cl, []runtimeclient.Object{newConfigMap("john-dev", "first", nil, withLabels(ownerLabels)), newConfigMap("john-dev", "second", nil, withLabels(ownerLabels))},
+			client.DeletePollInterval(10*time.Millisecond))
+
+		// then
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Contains(t, err.Error(), "the deletion of the following resources did not complete: "+
+			"ConfigMap 'john-dev/first' (finalizers: finalizer.toolchain.dev.openshift.com, other.toolchain.dev.openshift.com)")
+		require.Len(t, remaining, 1)
+		assert.Equal(t, "ConfigMap", remaining[0].GVK.Kind)
+		assert.Equal(t, types.NamespacedName{Namespace: "john-dev", Name: "first"}, remaining[0].NamespacedName)
+		assert.Equal(t, []string{"finalizer.toolchain.dev.openshift.com", "other.toolchain.dev.openshift.com"}, remaining[0].Finalizers)
+		assert.False(t, exists(t, cl, "second"))
+	})
+
+	t.Run("should report last known blocking objects when they cannot be checked", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t, newConfigMap("john-dev", "first", nil, withLabels(ownerLabels), withFinalizers("finalizer.toolchain.dev.openshift.com")))
+		gets := 0
+		cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			gets++
+			if gets > 1 {
+				return fmt.Errorf("mock error")
+			}
+			return cl.Client.Get(ctx, key, obj, opts...)
+		}
+		ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+		defer cancel()
+
+		// when
+		remaining, err := client.DeleteAndWait(ctx, cl, []runtimeclient.Object{newConfigMap("john-dev", "first", nil, withLabels(ownerLabels))},
+			client.DeletePollInterval(10*time.Millisecond))
+
+		// then
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Contains(t, err.Error(), "the deletion of the following resources did not complete: "+
+			"ConfigMap 'john-dev/first' (finalizers: finalizer.toolchain.dev.openshift.com)")
+		require.Len(t, remaining, 1)
+		assert.Equal(t, types.NamespacedName{Namespace: "john-dev", Name: "first"}, remaining[0].NamespacedName)
+		assert.Greater(t, gets, 2) // kept checking after the failure
+	})
+
+	t.Run("should keep waiting when objects cannot be checked temporarily", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t, newConfigMap("john-dev", "first", nil, withLabels(ownerLabels), withFinalizers("finalizer.toolchain.dev.openshift.com")))
+		gets := 0
+		cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			gets++
+			if gets == 2 {
+				// the finalizer is removed while the object cannot be read
+				cm := &corev1.ConfigMap{}
+				if err := cl.Client.Get(ctx, key, cm); err != nil {
+					return err
+				}
+				cm.Finalizers = nil
+				if err := cl.Client.Update(ctx, cm); err != nil {
+					return err
+				}
+				return fmt.Errorf("mock error")
+			}
+			return cl.Client.Get(ctx, key, obj, opts...)
+		}
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+
+		// when
+		remaining, err := client.DeleteAndWait(ctx, cl, []runtimeclient.Object{newConfigMap("john-dev", "first", nil, withLabels(ownerLabels))},
+			client.DeletePollInterval(10*time.Millisecond))
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, remaining)
+		assert.False(t, exists(t, cl, "first"))
+	})
+
+	t.Run("should report remaining objects without waiting", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t, newConfigMap("john-dev", "first", nil, withLabels(ownerLabels), withFinalizers("finalizer.toolchain.dev.openshift.com")), newConfigMap("john-dev", "second", nil, withLabels(ownerLabels)))
+
+		// when
+		remaining, err := client.DeleteAndWait(context.TODO(), cl, []runtimeclient.Object{newConfigMap("john-dev", "first", nil, withLabels(ownerLabels)), newConfigMap("john-dev", "second", nil, withLabels(ownerLabels))},
+			client.WaitForDeletion(false))
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, "ConfigMap 'john-dev/first' (finalizers: finalizer.toolchain.dev.openshift.com)", remaining[0].String())
+		assert.True(t, exists(t, cl, "first"))
+	})
+
+	t.Run("should ignore object recreated with another UID", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t)
+		cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			obj.SetName(key.Name)
+			obj.SetNamespace(key.Namespace)
+			obj.SetUID("new-uid")
+			return nil
+		}
+		deleted := newConfigMap("john-dev", "first", nil, withLabels(ownerLabels))
+		deleted.UID = "old-uid"
+
+		// when
+		remaining, err := client.DeleteAndWait(context.TODO(), cl, []runtimeclient.Object{deleted})
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, remaining)
+	})
+
+	t.Run("should fail when deletion fails", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t, newConfigMap("john-dev", "first", nil, withLabels(ownerLabels)))
+		cl.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+			return fmt.Errorf("mock error")
+		}
+
+		// when
+		_, err := client.DeleteAndWait(context.TODO(), cl, []runtimeclient.Object{newConfigMap("john-dev", "first", nil, withLabels(ownerLabels))})
+
+		// then
+		require.EqualError(t, err, "unable to delete the resource 'john-dev/first': mock error")
+	})
+
+	t.Run("should delete objects matching selector", func(t *testing.T) {
+		// given
+		other := newConfigMap("john-dev", "other", nil, withLabels(ownerLabels))
+		other.Labels = map[string]string{"toolchain.dev.openshift.com/owner": "jane"}
+		cl := NewFakeClient(t, newConfigMap("john-dev", "first", nil, withLabels(ownerLabels)), newConfigMap("john-dev", "second", nil, withLabels(ownerLabels), withFinalizers("finalizer.toolchain.dev.openshift.com")), other)
+		selector := labels.SelectorFromSet(labels.Set{"toolchain.dev.openshift.com/owner": "john"})
+
+		// when
+		remaining, err := client.DeleteBySelectorAndWait(context.TODO(), cl, &corev1.ConfigMapList{}, "john-dev", selector, client.WaitForDeletion(false))
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, "second", remaining[0].NamespacedName.Name)
+		assert.False(t, exists(t, cl, "first"))
+		assert.True(t, exists(t, cl, "other"))
+	})
+}
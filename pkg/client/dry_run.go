@@ -0,0 +1,262 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+// DryRunStrategy the strategy to use when running the apply in dry-run mode
+type DryRunStrategy string
+
+const (
+	// DryRunServer sends the requests to the server with the `dryRun=All` option,
+	// so that the objects are validated, defaulted and admitted by the server but not persisted
+	DryRunServer DryRunStrategy = "server"
+	// DryRunClient does not send any write request to the server. The live objects are compared with
+	// the desired objects on the client side only (which is useful with clients that do not support server-side dry-run)
+	DryRunClient DryRunStrategy = "client"
+)
+
+// DryRunAction what would happen to an object if it was applied
+type DryRunAction string
+
+const (
+	// DryRunCreated the object would be created
+	DryRunCreated DryRunAction = "created"
+	// DryRunUpdated the object would be updated
+	DryRunUpdated DryRunAction = "updated"
+	// DryRunUnchanged the object would not change
+	DryRunUnchanged DryRunAction = "unchanged"
+)
+
+// redactedValue the value which replaces the content of the data in Secrets in the diffs
+const redactedValue = "***"
+
+// DryRunReport the report of a dry-run apply for a single object
+type DryRunReport struct {
+	// GVK the GroupVersionKind of the object
+	GVK schema.GroupVersionKind
+	// NamespacedName the namespace and name of the object
+	NamespacedName types.NamespacedName
+	// Action what would happen to the object
+	Action DryRunAction
+	// Diff a unified diff between the live object and the desired object, ignoring the metadata and the status.
+	// The data of Secrets is redacted. The diff is empty if the content would not change.
+	Diff string
+}
+
+// DryRun runs the apply of the given objects (see `Apply`) in dry-run mode, using the given strategy, and returns a report for each object.
+// Nothing is persisted on the cluster.
+func (c ApplyClient) DryRun(toolchainObjects []client.Object, newLabels map[string]string, strategy DryRunStrategy) ([]DryRunReport, error) {
+	return c.DryRunCtx(context.TODO(), toolchainObjects, newLabels, strategy)
+}
+
+// DryRunCtx is the same as DryRun, but all the requests to the server are bound to the given context
+func (c ApplyClient) DryRunCtx(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string, strategy DryRunStrategy) ([]DryRunReport, error) {
+	reports := make([]DryRunReport, 0, len(toolchainObjects))
+	for _, toolchainObject := range toolchainObjects {
+		// do not alter the given objects
+		desired := toolchainObject.DeepCopyObject().(client.Object)
+		MergeLabels(desired, newLabels)
+		report, err := c.dryRunObject(ctx, desired, strategy)
+		if err != nil {
+			gvk := toolchainObject.GetObjectKind().GroupVersionKind()
+			return nil, errors.Wrapf(err, "unable to run dry-run for resource of kind: %s, version: %s", gvk.Kind, gvk.Version)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func (c ApplyClient) dryRunObject(ctx context.Context, desired client.Object, strategy DryRunStrategy) (DryRunReport, error) {
+	gvk, err := apiutil.GVKForObject(desired, c.Client.Scheme())
+	if err != nil {
+		return DryRunReport{}, errors.Wrapf(err, "unable to get the GroupVersionKind of the resource '%v'", desired)
+	}
+	report := DryRunReport{
+		GVK:            gvk,
+		NamespacedName: types.NamespacedName{Namespace: desired.GetNamespace(), Name: desired.GetName()},
+	}
+
+	var live client.Object
+	existing := desired.DeepCopyObject().(client.Object)
+	if err := c.Client.Get(ctx, report.NamespacedName, existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return DryRunReport{}, errors.Wrapf(err, "unable to get the resource '%v'", existing)
+		}
+	} else {
+		live = existing
+	}
+
+	switch strategy {
+	case DryRunServer:
+		// the desired object is updated with the response of the server
+		if _, err := c.applyObjectWithRetries(ctx, desired, ForceUpdate(true), func(config *applyObjectConfiguration) {
+			config.dryRun = true
+		}); err != nil {
+			return DryRunReport{}, err
+		}
+	case DryRunClient:
+		desired.SetAnnotations(withLastAppliedConfiguration(desired))
+		if live != nil {
+			if _, err := retainImmutableFields(gvk, desired, live); err != nil {
+				return DryRunReport{}, err
+			}
+			if err := RetainClusterIP(desired, live); err != nil {
+				return DryRunReport{}, err
+			}
+		}
+	default:
+		return DryRunReport{}, fmt.Errorf("unknown dry-run strategy: '%s'", strategy)
+	}
+
+	if report.Diff, err = diff(gvk, live, desired); err != nil {
+		return DryRunReport{}, errors.Wrapf(err, "unable to compute the diff of the resource '%v'", desired)
+	}
+	switch {
+	case live == nil:
+		report.Action = DryRunCreated
+	case report.Diff != "" ||
+		!reflect.DeepEqual(live.GetLabels(), desired.GetLabels()) ||
+		!reflect.DeepEqual(withoutLastAppliedConfiguration(live), withoutLastAppliedConfiguration(desired)) ||
+		!sameLastAppliedConfiguration(desired, live):
+		report.Action = DryRunUpdated
+	default:
+		report.Action = DryRunUnchanged
+	}
+	return report, nil
+}
+
+// withLastAppliedConfiguration returns the annotations of the given object, including the last applied configuration
+func withLastAppliedConfiguration(obj client.Object) map[string]string {
+	annotations := map[string]string{}
+	for k, v := range obj.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[LastAppliedConfigurationAnnotationKey] = getNewConfiguration(obj)
+	return annotations
+}
+
+// sameLastAppliedConfiguration returns `true` if the last applied configurations of the desired and the live objects are the same,
+// regardless of the encoding of the last applied configuration of the live object
+func sameLastAppliedConfiguration(desired, live client.Object) bool {
+	desiredConfiguration := desired.GetAnnotations()[LastAppliedConfigurationAnnotationKey]
+	content, err := decodeConfiguration(desiredConfiguration)
+	if err != nil {
+		return false
+	}
+	return sameConfiguration(content, desiredConfiguration, live.GetAnnotations()[LastAppliedConfigurationAnnotationKey])
+}
+
+// diff returns the unified diff between the content of the live and the desired objects.
+// The live object may be nil if it does not exist yet.
+func diff(gvk schema.GroupVersionKind, live, desired runtime.Object) (string, error) {
+	liveContent, err := diffableContent(gvk, live)
+	if err != nil {
+		return "", err
+	}
+	desiredContent, err := diffableContent(gvk, desired)
+	if err != nil {
+		return "", err
+	}
+	if gvk.Group == "" && gvk.Kind == "Secret" {
+		redactSecretData(liveContent, desiredContent)
+	}
+	liveYAML, err := toYAML(liveContent)
+	if err != nil {
+		return "", err
+	}
+	desiredYAML, err := toYAML(desiredContent)
+	if err != nil {
+		return "", err
+	}
+	if liveYAML == desiredYAML {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(liveYAML),
+		B:        splitLines(desiredYAML),
+		FromFile: "live",
+		ToFile:   "desired",
+		Context:  3,
+	})
+}
+
+// diffableContent returns the content of the given object, without its `apiVersion`, `kind`, `metadata` and `status`
+func diffableContent(gvk schema.GroupVersionKind, obj runtime.Object) (map[string]interface{}, error) {
+	if obj == nil {
+		return map[string]interface{}{}, nil
+	}
+	c, err := unstructuredContent(obj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to convert the resource of kind '%s'", gvk.Kind)
+	}
+	content := runtime.DeepCopyJSON(c)
+	for _, field := range []string{"apiVersion", "kind", "metadata", "status"} {
+		delete(content, field)
+	}
+	return content, nil
+}
+
+// redactSecretData replaces the values in the `data` and `stringData` fields of the given secret contents,
+// while keeping track of the values that differ between the live and the desired objects
+func redactSecretData(live, desired map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		liveData, _, _ := unstructured.NestedMap(live, field)
+		desiredData, _, _ := unstructured.NestedMap(desired, field)
+		for k, liveValue := range liveData {
+			if desiredValue, found := desiredData[k]; found && reflect.DeepEqual(liveValue, desiredValue) {
+				liveData[k] = redactedValue
+				desiredData[k] = redactedValue
+				continue
+			}
+			liveData[k] = redactedValue + " (before)"
+		}
+		for k, desiredValue := range desiredData {
+			if desiredValue != redactedValue {
+				desiredData[k] = redactedValue + " (after)"
+			}
+		}
+		if liveData != nil {
+			live[field] = liveData
+		}
+		if desiredData != nil {
+			desired[field] = desiredData
+		}
+	}
+}
+
+func toYAML(content map[string]interface{}) (string, error) {
+	if len(content) == 0 {
+		return "", nil
+	}
+	out, err := yaml.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,196 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDryRun(t *testing.T) {
+	// given
+	addToScheme(t)
+	labels := newLabels("base1ns", "john", "dev")
+
+	newSecret := func(value string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "john-dev",
+			},
+			Data: map[string][]byte{
+				"password": []byte(value),
+				"username": []byte("john"),
+			},
+		}
+	}
+
+	// the objects which were already applied
+	applied := []runtimeclient.Object{newConfigMap("john-dev", "unchanged", map[string]string{"param": "value"}), newConfigMap("john-dev", "updated", map[string]string{"param": "first-value"}), newSecret("secret-value")}
+
+	t.Run("using client strategy", func(t *testing.T) {
+		// given
+		cl, cli := newClientWithAppliedObjects(t, labels, applied...)
+
+		// when
+		reports, err := cl.DryRun([]runtimeclient.Object{
+			newConfigMap("john-dev", "created", map[string]string{"param": "value"}),
+			newConfigMap("john-dev", "unchanged", map[string]string{"param": "value"}),
+			newConfigMap("john-dev", "updated", map[string]string{"param": "second-value"}),
+			newSecret("new-secret-value"),
+		}, labels, client.DryRunClient)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, reports, 4)
+
+		assert.Equal(t, "ConfigMap", reports[0].GVK.Kind)
+		assert.Equal(t, types.NamespacedName{Namespace: "john-dev", Name: "created"}, reports[0].NamespacedName)
+		assert.Equal(t, client.DryRunCreated, reports[0].Action)
+		assert.Equal(t, `--- live
++++ desired
+@@ -0,0 +1,2 @@
++data:
++  param: value
+`, reports[0].Diff)
+
+		assert.Equal(t, client.DryRunUnchanged, reports[1].Action)
+		assert.Empty(t, reports[1].Diff)
+
+		assert.Equal(t, client.DryRunUpdated, reports[2].Action)
+		assert.Equal(t, `--- live
++++ desired
+@@ -1,2 +1,2 @@
+ data:
+-  param: first-value
++  param: second-value
+`, reports[2].Diff)
+
+		// the data of the secret is redacted
+		assert.Equal(t, client.DryRunUpdated, reports[3].Action)
+		assert.Equal(t, `--- live
++++ desired
+@@ -1,3 +1,3 @@
+ data:
+-  password: '*** (before)'
++  password: '*** (after)'
+   username: '***'
+`, reports[3].Diff)
+		assert.NotContains(t, reports[3].Diff, "secret-value")
+
+		// nothing was changed on the cluster
+		err = cli.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "created"}, &corev1.ConfigMap{})
+		require.Error(t, err)
+		cm := &corev1.ConfigMap{}
+		err = cli.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "updated"}, cm)
+		require.NoError(t, err)
+		assert.Equal(t, "first-value", cm.Data["param"])
+	})
+
+	t.Run("using client strategy when labels change", func(t *testing.T) {
+		// given
+		cl, _ := newClientWithAppliedObjects(t, labels, applied...)
+
+		// when
+		reports, err := cl.DryRun([]runtimeclient.Object{newConfigMap("john-dev", "unchanged", map[string]string{"param": "value"})}, newLabels("advanced", "john", "dev"), client.DryRunClient)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.Equal(t, client.DryRunUpdated, reports[0].Action)
+		assert.Empty(t, reports[0].Diff)
+	})
+
+	t.Run("using server strategy", func(t *testing.T) {
+		// given
+		cl, cli := newClientWithAppliedObjects(t, labels, applied...)
+		var dryRunCreate, dryRunUpdate []string
+		cli.MockCreate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+			createOptions := &runtimeclient.CreateOptions{}
+			createOptions.ApplyOptions(opts)
+			dryRunCreate = append(dryRunCreate, createOptions.DryRun...)
+			return cli.Client.Create(ctx, obj, opts...)
+		}
+		cli.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+			updateOptions := &runtimeclient.UpdateOptions{}
+			updateOptions.ApplyOptions(opts)
+			dryRunUpdate = append(dryRunUpdate, updateOptions.DryRun...)
+			return cli.Client.Update(ctx, obj, opts...)
+		}
+
+		// when
+		reports, err := cl.DryRun([]runtimeclient.Object{
+			newConfigMap("john-dev", "created", map[string]string{"param": "value"}),
+			newConfigMap("john-dev", "unchanged", map[string]string{"param": "value"}),
+			newConfigMap("john-dev", "updated", map[string]string{"param": "second-value"}),
+		}, labels, client.DryRunServer)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, reports, 3)
+		assert.Equal(t, client.DryRunCreated, reports[0].Action)
+		assert.Equal(t, client.DryRunUnchanged, reports[1].Action)
+		assert.Equal(t, client.DryRunUpdated, reports[2].Action)
+		assert.Contains(t, reports[2].Diff, "+  param: second-value")
+		assert.Equal(t, []string{metav1.DryRunAll}, dryRunCreate)
+		assert.Equal(t, []string{metav1.DryRunAll, metav1.DryRunAll}, dryRunUpdate)
+		// nothing was changed on the cluster
+		err = cli.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "created"}, &corev1.ConfigMap{})
+		require.Error(t, err)
+		cm := &corev1.ConfigMap{}
+		err = cli.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "updated"}, cm)
+		require.NoError(t, err)
+		assert.Equal(t, "first-value", cm.Data["param"])
+	})
+
+	t.Run("given objects are not modified", func(t *testing.T) {
+		// given
+		cl, _ := newClientWithAppliedObjects(t, labels, applied...)
+		cm := newConfigMap("john-dev", "updated", map[string]string{"param": "second-value"})
+
+		// when
+		_, err := cl.DryRun([]runtimeclient.Object{cm}, labels, client.DryRunClient)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, cm.Labels)
+		assert.Empty(t, cm.Annotations)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("unknown strategy", func(t *testing.T) {
+			// given
+			cl, _ := newClientWithAppliedObjects(t, labels, applied...)
+
+			// when
+			_, err := cl.DryRun([]runtimeclient.Object{newConfigMap("john-dev", "created", map[string]string{"param": "value"})}, labels, "unknown")
+
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unknown dry-run strategy: 'unknown'")
+		})
+
+		t.Run("server rejects the object", func(t *testing.T) {
+			// given
+			cl, cli := newClientWithAppliedObjects(t, labels, applied...)
+			cli.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				return errors.New("mock error")
+			}
+
+			// when
+			_, err := cl.DryRun([]runtimeclient.Object{newConfigMap("john-dev", "updated", map[string]string{"param": "second-value"})}, labels, client.DryRunServer)
+
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "mock error")
+		})
+	})
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/go-github/v52/github"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The keys of the GitHub App credentials in a Secret
+const (
+	GitHubAppIDKey             = "app-id"
+	GitHubAppInstallationIDKey = "installation-id"
+	GitHubAppPrivateKeyKey     = "private-key"
+)
+
+const (
+	// gitHubAppJWTExpiration the expiration of the JWTs used to authenticate as a GitHub App (the maximum allowed by GitHub is 10 minutes)
+	gitHubAppJWTExpiration = 9 * time.Minute
+	// gitHubAppTokenEarlyExpiry the delay before the expiry of a token (JWT or installation token) when a new token is created
+	gitHubAppTokenEarlyExpiry = time.Minute
+)
+
+// GitHubAppCredentials the credentials of a GitHub App installation
+type GitHubAppCredentials struct {
+	// AppID the ID of the GitHub App
+	AppID int64
+	// InstallationID the ID of the installation of the GitHub App in the organization
+	InstallationID int64
+	// PrivateKey the PEM-encoded private key of the GitHub App
+	PrivateKey []byte
+}
+
+// GitHubAppCredentialsFromSecret reads the GitHub App credentials from the `app-id`, `installation-id` and `private-key` keys of the given Secret
+func GitHubAppCredentialsFromSecret(secret *corev1.Secret) (GitHubAppCredentials, error) {
+	appID, err := strconv.ParseInt(strings.TrimSpace(string(secret.Data[GitHubAppIDKey])), 10, 64)
+	if err != nil {
+		return GitHubAppCredentials{}, errors.Wrapf(err, "invalid GitHub App ID in the secret '%s'", secret.Name)
+	}
+	installationID, err := strconv.ParseInt(strings.TrimSpace(string(secret.Data[GitHubAppInstallationIDKey])), 10, 64)
+	if err != nil {
+		return GitHubAppCredentials{}, errors.Wrapf(err, "invalid GitHub App installation ID in the secret '%s'", secret.Name)
+	}
+	privateKey := secret.Data[GitHubAppPrivateKeyKey]
+	if len(privateKey) == 0 {
+		return GitHubAppCredentials{}, errors.Errorf("missing GitHub App private key in the secret '%s'", secret.Name)
+	}
+	return GitHubAppCredentials{
+		AppID:          appID,
+		InstallationID: installationID,
+		PrivateKey:     privateKey,
+	}, nil
+}
+
+// GitHubAppOption an option to configure the authentication as a GitHub App
+type GitHubAppOption func(*gitHubAppConfiguration)
+
+type gitHubAppConfiguration struct {
+	baseURL string
+	cache   *GitHubCache
+}
+
+type gitHubAppClientConfiguration struct {
+	baseURL *url.URL
+	cache   *GitHubCache
+}
+
+// GitHubAppBaseURL sets the base URL of the GitHub API, eg, for GitHub Enterprise (default: `https://api.github.com/`)
+func GitHubAppBaseURL(baseURL string) GitHubAppOption {
+	return func(config *gitHubAppConfiguration) {
+		config.baseURL = baseURL
+	}
+}
+
+// GitHubAppCache sets the cache used by the GitHub clients (default: `DefaultGitHubCache`)
+func GitHubAppCache(cache *GitHubCache) GitHubAppOption {
+	return func(config *gitHubAppConfiguration) {
+		config.cache = cache
+	}
+}
+
+// NewGitHubAppTokenSource returns a token source of installation tokens of the GitHub App with the given credentials.
+// The installation tokens are created with a short-lived JWT signed with the private key of the GitHub App,
+// and are reused until they are about to expire.
+func NewGitHubAppTokenSource(credentials GitHubAppCredentials, options ...GitHubAppOption) (oauth2.TokenSource, error) {
+	config, err := newGitHubAppConfiguration(options...)
+	if err != nil {
+		return nil, err
+	}
+	jwtSource, err := newGitHubAppJWTSource(credentials)
+	if err != nil {
+		return nil, err
+	}
+	appClient := github.NewClient(oauth2.NewClient(context.TODO(), oauth2.ReuseTokenSourceWithExpiry(nil, jwtSource, gitHubAppTokenEarlyExpiry)))
+	if config.baseURL != nil {
+		appClient.BaseURL = config.baseURL
+	}
+	return oauth2.ReuseTokenSourceWithExpiry(nil, &gitHubAppInstallationTokenSource{
+		client:         appClient,
+		installationID: credentials.InstallationID,
+	}, gitHubAppTokenEarlyExpiry), nil
+}
+
+// NewGitHubAppClient returns a GitHub client authenticated as the installation of the GitHub App with the given credentials.
+// Like the clients returned by `NewGitHubClient`, it sends conditional requests and honors the rate limits.
+// Since GitHub counts the rate limit per installation, the entries of the cache are kept per installation
+// rather than per installation token, so that they survive the rotation of the tokens (see `GitHubCache.InstallationRateLimit`).
+func NewGitHubAppClient(credentials GitHubAppCredentials, options ...GitHubAppOption) (*github.Client, error) {
+	config, err := newGitHubAppConfiguration(options...)
+	if err != nil {
+		return nil, err
+	}
+	tokenSource, err := NewGitHubAppTokenSource(credentials, options...)
+	if err != nil {
+		return nil, err
+	}
+	return newGitHubAppClient(tokenSource, config, credentials), nil
+}
+
+// NewGitHubAppClientFunc returns a func which returns GitHub clients authenticated as the installation of the GitHub App
+// with the given credentials, to be used by the `VersionCheckManager` instead of `NewGitHubClient`.
+// The access token given to the returned func is ignored. The installation tokens are shared by all the returned clients.
+func NewGitHubAppClientFunc(credentials GitHubAppCredentials, options ...GitHubAppOption) (GetGitHubClientFunc, error) {
+	config, err := newGitHubAppConfiguration(options...)
+	if err != nil {
+		return nil, err
+	}
+	tokenSource, err := NewGitHubAppTokenSource(credentials, options...)
+	if err != nil {
+		return nil, err
+	}
+	return func(string) *github.Client {
+		return newGitHubAppClient(tokenSource, config, credentials)
+	}, nil
+}
+
+func newGitHubAppConfiguration(options ...GitHubAppOption) (gitHubAppClientConfiguration, error) {
+	config := gitHubAppConfiguration{
+		cache: DefaultGitHubCache,
+	}
+	for _, configure := range options {
+		configure(&config)
+	}
+	clientConfig := gitHubAppClientConfiguration{
+		cache: config.cache,
+	}
+	if config.baseURL != "" {
+		baseURL := config.baseURL
+		if !strings.HasSuffix(baseURL, "/") {
+			baseURL += "/"
+		}
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return clientConfig, errors.Wrapf(err, "invalid GitHub API base URL '%s'", config.baseURL)
+		}
+		clientConfig.baseURL = u
+	}
+	return clientConfig, nil
+}
+
+func newGitHubAppClient(tokenSource oauth2.TokenSource, config gitHubAppClientConfiguration, credentials GitHubAppCredentials) *github.Client {
+	ctx := context.WithValue(context.TODO(), oauth2.HTTPClient, &http.Client{
+		Transport: &gitHubTransport{
+			base:     http.DefaultTransport,
+			cache:    config.cache,
+			cacheKey: gitHubAppCacheKey(credentials.AppID, credentials.InstallationID),
+		},
+	})
+	cl := github.NewClient(oauth2.NewClient(ctx, tokenSource))
+	if config.baseURL != nil {
+		cl.BaseURL = config.baseURL
+	}
+	return cl
+}
+
+// gitHubAppCacheKey returns the key of the entries of the given installation of a GitHub App in the GitHubCache
+func gitHubAppCacheKey(appID, installationID int64) string {
+	return fmt.Sprintf("app/%d/installation/%d", appID, installationID)
+}
+
+// gitHubAppJWTSource a token source of JWTs to authenticate as a GitHub App
+type gitHubAppJWTSource struct {
+	appID      int64
+	privateKey interface{}
+}
+
+func newGitHubAppJWTSource(credentials GitHubAppCredentials) (*gitHubAppJWTSource, error) {
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(credentials.PrivateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid GitHub App private key")
+	}
+	return &gitHubAppJWTSource{
+		appID:      credentials.AppID,
+		privateKey: privateKey,
+	}, nil
+}
+
+func (s *gitHubAppJWTSource) Token() (*oauth2.Token, error) {
+	now := time.Now()
+	expiry := now.Add(gitHubAppJWTExpiration)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.StandardClaims{
+		// allow some clock drift with the GitHub servers
+		IssuedAt:  now.Add(-time.Minute).Unix(),
+		ExpiresAt: expiry.Unix(),
+		Issuer:    strconv.FormatInt(s.appID, 10),
+	}).SignedString(s.privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to sign the GitHub App JWT")
+	}
+	return &oauth2.Token{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		Expiry:      expiry,
+	}, nil
+}
+
+// gitHubAppInstallationTokenSource a token source of installation tokens of a GitHub App
+type gitHubAppInstallationTokenSource struct {
+	client         *github.Client
+	installationID int64
+}
+
+func (s *gitHubAppInstallationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.client.Apps.CreateInstallationToken(context.TODO(), s.installationID, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create a token for the GitHub App installation %d", s.installationID)
+	}
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "Bearer",
+		Expiry:      token.GetExpiresAt().Time,
+	}, nil
+}
@@ -1,7 +1,15 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/v52/github"
@@ -11,6 +19,13 @@ import (
 // GitHubAPICallDelay it's used to "slow" down the number of requests we perform to GitHub API , in order to avoid rate limit issues.
 const GitHubAPICallDelay = 1 * time.Minute
 
+const (
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+	headerETag               = "ETag"
+	headerIfNoneMatch        = "If-None-Match"
+)
+
 // GetGitHubClientFunc a func that returns a GitHub client instance
 type GetGitHubClientFunc func(string) *github.Client
 
@@ -21,15 +36,268 @@ type GitHubRepository struct {
 // NewGitHubClient return a client that interacts with GitHub and has rate limiter configured.
 // With authenticated GitHub api you can make 5,000 requests per hour.
 // see: https://github.com/google/go-github#rate-limiting
+// The client shares the `DefaultGitHubCache` with all the other clients, so that the responses of the GET requests
+// (eg, the last commit of a repository and branch) are revalidated with conditional requests which do not count against the rate limit,
+// and that no request is sent once the rate limit of the access token is exhausted, until it is reset.
 func NewGitHubClient(accessToken string) *github.Client {
+	return NewGitHubClientWithCache(accessToken, DefaultGitHubCache)
+}
+
+// NewGitHubClientWithCache is the same as NewGitHubClient, but uses the given cache
+func NewGitHubClientWithCache(accessToken string, cache *GitHubCache) *github.Client {
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: accessToken},
 	)
-	tc := oauth2.NewClient(context.TODO(), ts)
+	// the authorization header is set by the oauth2 transport before the request reaches the cache transport
+	ctx := context.WithValue(context.TODO(), oauth2.HTTPClient, &http.Client{
+		Transport: NewGitHubTransport(http.DefaultTransport, cache),
+	})
+	tc := oauth2.NewClient(ctx, ts)
 	return github.NewClient(tc)
 }
 
 // CanIssueGitHubRequest checks if we already called the GitHub API and if call it again since the preconfigured threshold delay expired.
 func CanIssueGitHubRequest(lastGitHubAPICall time.Time) bool {
-	return lastGitHubAPICall.IsZero() || time.Now().After(lastGitHubAPICall.Add(GitHubAPICallDelay))
+	return CanIssueGitHubRequestWithDelay(lastGitHubAPICall, GitHubAPICallDelay)
+}
+
+// CanIssueGitHubRequestWithDelay checks if we already called the GitHub API and if call it again since the given delay expired.
+func CanIssueGitHubRequestWithDelay(lastGitHubAPICall time.Time, delay time.Duration) bool {
+	return lastGitHubAPICall.IsZero() || time.Now().After(lastGitHubAPICall.Add(delay))
+}
+
+// DefaultGitHubCacheMaxResponses the default maximum number of responses kept in a GitHubCache
+const DefaultGitHubCacheMaxResponses = 1000
+
+// DefaultGitHubCache the cache shared by the GitHub clients created with `NewGitHubClient`
+var DefaultGitHubCache = NewGitHubCache()
+
+// GitHubCache keeps the ETag and the content of the last successful responses of the GET requests sent to the GitHub API,
+// as well as the rate limit of each access token, as returned in the `X-RateLimit-Remaining` and `X-RateLimit-Reset` headers.
+// The entries are specific to each access token, or to each installation for the GitHub App clients (see `NewGitHubAppClient`).
+// The least recently used responses are evicted once the maximum number of responses is reached,
+// and the rate limits are evicted once they are reset.
+type GitHubCache struct {
+	lock         sync.RWMutex
+	responses    map[string]cachedGitHubResponse
+	maxResponses int
+	rateLimits   map[string]github.Rate
+}
+
+type cachedGitHubResponse struct {
+	etag     string
+	header   http.Header
+	body     []byte
+	lastUsed time.Time
+}
+
+// GitHubCacheOption an option to configure a GitHubCache
+type GitHubCacheOption func(*GitHubCache)
+
+// GitHubCacheMaxResponses sets the maximum number of responses kept in the cache (default: `DefaultGitHubCacheMaxResponses`)
+func GitHubCacheMaxResponses(max int) GitHubCacheOption {
+	return func(cache *GitHubCache) {
+		cache.maxResponses = max
+	}
+}
+
+// NewGitHubCache returns a new, empty cache configured with the given options
+func NewGitHubCache(options ...GitHubCacheOption) *GitHubCache {
+	cache := &GitHubCache{
+		responses:    map[string]cachedGitHubResponse{},
+		maxResponses: DefaultGitHubCacheMaxResponses,
+		rateLimits:   map[string]github.Rate{},
+	}
+	for _, configure := range options {
+		configure(cache)
+	}
+	return cache
+}
+
+// RateLimit returns the last known rate limit of the given access token, and `false` if it is unknown
+func (c *GitHubCache) RateLimit(accessToken string) (github.Rate, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	rate, found := c.rateLimits[tokenID("Bearer "+accessToken)]
+	return rate, found
+}
+
+// InstallationRateLimit returns the last known rate limit of the given installation of a GitHub App, and `false` if it is unknown
+func (c *GitHubCache) InstallationRateLimit(appID, installationID int64) (github.Rate, bool) {
+	return c.getRateLimit(gitHubAppCacheKey(appID, installationID))
+}
+
+func (c *GitHubCache) getResponse(key string) (cachedGitHubResponse, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	resp, found := c.responses[key]
+	if found {
+		resp.lastUsed = time.Now()
+		c.responses[key] = resp
+	}
+	return resp, found
+}
+
+func (c *GitHubCache) setResponse(key string, resp cachedGitHubResponse) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	resp.lastUsed = time.Now()
+	c.responses[key] = resp
+	// evict the least recently used responses
+	for len(c.responses) > c.maxResponses {
+		oldestKey := ""
+		var oldest time.Time
+		for k, r := range c.responses {
+			if oldestKey == "" || r.lastUsed.Before(oldest) {
+				oldestKey, oldest = k, r.lastUsed
+			}
+		}
+		delete(c.responses, oldestKey)
+	}
+}
+
+func (c *GitHubCache) getRateLimit(token string) (github.Rate, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	rate, found := c.rateLimits[token]
+	return rate, found
+}
+
+func (c *GitHubCache) setRateLimit(token string, header http.Header) {
+	remaining, err := strconv.Atoi(header.Get(headerRateLimitRemaining))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get(headerRateLimitReset), 10, 64)
+	if err != nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// the rate limits which were reset are not needed anymore
+	now := time.Now()
+	for k, rate := range c.rateLimits {
+		if rate.Reset.Before(now) {
+			delete(c.rateLimits, k)
+		}
+	}
+	c.rateLimits[token] = github.Rate{
+		Remaining: remaining,
+		Reset:     github.Timestamp{Time: time.Unix(reset, 0)},
+	}
+}
+
+// NewGitHubTransport returns a transport which uses the given cache to:
+//   - send conditional requests (with the `If-None-Match` header) for the GET requests whose response is in the cache,
+//     and return the cached response when GitHub replies with `304 Not Modified` (which does not count against the rate limit),
+//   - not send any request while the rate limit of the access token is exhausted: a `403 Forbidden` response is returned instead
+//     (even if the response is in the cache, since it may be stale), which is converted into a `github.RateLimitError` by the GitHub client.
+func NewGitHubTransport(base http.RoundTripper, cache *GitHubCache) http.RoundTripper {
+	return &gitHubTransport{
+		base:  base,
+		cache: cache,
+	}
+}
+
+type gitHubTransport struct {
+	base  http.RoundTripper
+	cache *GitHubCache
+	// cacheKey the key of the entries in the cache, if they are not specific to the access token (eg, for a GitHub App installation)
+	cacheKey string
+}
+
+func (t *gitHubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := t.cacheKey
+	if token == "" {
+		token = tokenID(req.Header.Get("Authorization"))
+	}
+	key := token + " " + req.URL.String()
+	cached, found := t.cache.getResponse(key)
+	if req.Method != http.MethodGet {
+		found = false
+	}
+
+	if rate, known := t.cache.getRateLimit(token); known && rate.Remaining == 0 && time.Now().Before(rate.Reset.Time) {
+		return rateLimitExceededResponse(req, rate), nil
+	}
+
+	if found {
+		// do not modify the original request
+		req = req.Clone(req.Context())
+		req.Header.Set(headerIfNoneMatch, cached.etag)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.cache.setRateLimit(token, resp.Header)
+
+	switch {
+	case found && resp.StatusCode == http.StatusNotModified:
+		resp.Body.Close()
+		cachedResp := cached.response(req)
+		// keep the latest rate limit
+		for _, h := range []string{headerRateLimitRemaining, headerRateLimitReset} {
+			if v := resp.Header.Get(h); v != "" {
+				cachedResp.Header.Set(h, v)
+			}
+		}
+		return cachedResp, nil
+	case req.Method == http.MethodGet && resp.StatusCode == http.StatusOK && resp.Header.Get(headerETag) != "":
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		t.cache.setResponse(key, cachedGitHubResponse{
+			etag:   resp.Header.Get(headerETag),
+			header: resp.Header.Clone(),
+			body:   body,
+		})
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	default:
+		return resp, nil
+	}
+}
+
+// response returns a new response for the given request, with the cached content
+func (r cachedGitHubResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusOK, http.StatusText(http.StatusOK)),
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
+
+// rateLimitExceededResponse returns a response similar to the one returned by GitHub when the rate limit is exceeded
+func rateLimitExceededResponse(req *http.Request, rate github.Rate) *http.Response {
+	body := []byte(fmt.Sprintf(`{"message":"API rate limit exceeded, the rate limit will be reset at %s"}`, rate.Reset.Format(time.RFC3339)))
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(headerRateLimitRemaining, "0")
+	header.Set(headerRateLimitReset, strconv.FormatInt(rate.Reset.Unix(), 10))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusForbidden, http.StatusText(http.StatusForbidden)),
+		StatusCode:    http.StatusForbidden,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// tokenID returns an identifier of the given authorization header, so that the access tokens are not kept in the cache
+func tokenID(authorization string) string {
+	hash := sha256.Sum256([]byte(authorization))
+	return hex.EncodeToString(hash[:8])
 }
@@ -1,9 +1,17 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/google/go-github/v52/github"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -41,3 +49,168 @@ func TestCanIssueGitHubRequest(t *testing.T) {
 		require.True(t, ok) // ok to call
 	})
 }
+
+func TestGitHubClientCache(t *testing.T) {
+	// given
+	commit := &github.RepositoryCommit{SHA: github.String("1234abcd")}
+	// newServer returns a server which replies with the commit and the given ETag and rate limit headers,
+	// or with `304 Not Modified` when the ETag matches
+	newServer := func(t *testing.T, remaining int, reset time.Time) (*httptest.Server, *[]*http.Request) {
+		requests := &[]*http.Request{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*requests = append(*requests, r)
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			if r.Header.Get("If-None-Match") == `"etag-1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"etag-1"`)
+			w.Header().Set("Content-Type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(commit))
+		}))
+		t.Cleanup(server.Close)
+		return server, requests
+	}
+	newClient := func(t *testing.T, server *httptest.Server, accessToken string, cache *GitHubCache) *github.Client {
+		cl := NewGitHubClientWithCache(accessToken, cache)
+		baseURL, err := url.Parse(server.URL + "/")
+		require.NoError(t, err)
+		cl.BaseURL = baseURL
+		return cl
+	}
+
+	t.Run("should send conditional requests", func(t *testing.T) {
+		// given
+		server, requests := newServer(t, 4999, time.Now().Add(time.Hour))
+		cache := NewGitHubCache()
+
+		// when
+		first, _, err := newClient(t, server, "token", cache).Repositories.GetCommit(context.TODO(), "codeready-toolchain", "host-operator", "HEAD", nil)
+		require.NoError(t, err)
+		second, resp, err := newClient(t, server, "token", cache).Repositories.GetCommit(context.TODO(), "codeready-toolchain", "host-operator", "HEAD", nil)
+		require.NoError(t, err)
+
+		// then
+		assert.Equal(t, "1234abcd", first.GetSHA())
+		assert.Equal(t, "1234abcd", second.GetSHA())
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, *requests, 2)
+		assert.Empty(t, (*requests)[0].Header.Get("If-None-Match"))
+		assert.Equal(t, `"etag-1"`, (*requests)[1].Header.Get("If-None-Match"))
+		rate, found := cache.RateLimit("token")
+		require.True(t, found)
+		assert.Equal(t, 4999, rate.Remaining)
+	})
+
+	t.Run("should not share cache between tokens", func(t *testing.T) {
+		// given
+		server, requests := newServer(t, 4999, time.Now().Add(time.Hour))
+		cache := NewGitHubCache()
+
+		// when
+		_, _, err := newClient(t, server, "token", cache).Repositories.GetCommit(context.TODO(), "codeready-toolchain", "host-operator", "HEAD", nil)
+		require.NoError(t, err)
+		_, _, err = newClient(t, server, "other-token", cache).Repositories.GetCommit(context.TODO(), "codeready-toolchain", "host-operator", "HEAD", nil)
+		require.NoError(t, err)
+
+		// then
+		require.Len(t, *requests, 2)
+		assert.Empty(t, (*requests)[1].Header.Get("If-None-Match"))
+	})
+
+	t.Run("rate limit exhausted", func(t *testing.T) {
+
+		t.Run("should return rate limit error instead of cached response without sending request", func(t *testing.T) {
+			// given
+			server, requests := newServer(t, 0, time.Now().Add(time.Hour))
+			cache := NewGitHubCache()
+			_, _, err := newClient(t, server, "token", cache).Repositories.GetCommit(context.TODO(), "codeready-toolchain", "host-operator", "HEAD", nil)
+			require.NoError(t, err)
+
+			// when
+			_, _, err = newClient(t, server, "token", cache).Repositories.GetCommit(context.TODO(), "codeready-toolchain", "host-operator", "HEAD", nil)
+
+			// then
+			require.Error(t, err)
+			rateLimitErr := &github.RateLimitError{}
+			require.ErrorAs(t, err, &rateLimitErr)
+			assert.Len(t, *requests, 1)
+		})
+
+		t.Run("should return rate limit error without sending request", func(t *testing.T) {
+			// given
+			server, requests := newServer(t, 0, time.Now().Add(time.Hour))
+			cache := NewGitHubCache()
+			_, _, err := newClient(t, server, "token", cache).Repositories.GetCommit(context.TODO(), "codeready-toolchain", "host-operator", "HEAD", nil)
+			require.NoError(t, err)
+
+			// when
+			_, _, err = newClient(t, server, "token", cache).Repositories.GetCommit(context.TODO(), "codeready-toolchain", "member-operator", "HEAD", nil)
+
+			// then
+			require.Error(t, err)
+			rateLimitErr := &github.RateLimitError{}
+			require.ErrorAs(t, err, &rateLimitErr)
+			assert.Len(t, *requests, 1)
+		})
+
+		t.Run("should send request once rate limit is reset", func(t *testing.T) {
+			// given
+			server, requests := newServer(t, 0, time.Now().Add(-time.Second))
+			cache := NewGitHubCache()
+			_, _, err := newClient(t, server, "token", cache).Repositories.GetCommit(context.TODO(), "codeready-toolchain", "host-operator", "HEAD", nil)
+			require.NoError(t, err)
+
+			// when
+			_, _, err = newClient(t, server, "token", cache).Repositories.GetCommit(context.TODO(), "codeready-toolchain", "member-operator", "HEAD", nil)
+
+			// then
+			require.NoError(t, err)
+			assert.Len(t, *requests, 2)
+		})
+	})
+}
+
+func TestGitHubCacheEviction(t *testing.T) {
+	t.Run("should evict least recently used responses", func(t *testing.T) {
+		// given
+		cache := NewGitHubCache(GitHubCacheMaxResponses(2))
+		cache.setResponse("first", cachedGitHubResponse{etag: "1"})
+		time.Sleep(time.Millisecond)
+		cache.setResponse("second", cachedGitHubResponse{etag: "2"})
+		time.Sleep(time.Millisecond)
+		_, found := cache.getResponse("first")
+		require.True(t, found)
+
+		// when
+		cache.setResponse("third", cachedGitHubResponse{etag: "3"})
+
+		// then
+		assert.Len(t, cache.responses, 2)
+		assert.Contains(t, cache.responses, "first")
+		assert.NotContains(t, cache.responses, "second")
+		assert.Contains(t, cache.responses, "third")
+	})
+
+	t.Run("should evict reset rate limits", func(t *testing.T) {
+		// given
+		cache := NewGitHubCache()
+		header := func(reset time.Time) http.Header {
+			h := http.Header{}
+			h.Set("X-RateLimit-Remaining", "0")
+			h.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			return h
+		}
+		cache.setRateLimit("expired", header(time.Now().Add(-time.Minute)))
+		cache.setRateLimit("current", header(time.Now().Add(time.Hour)))
+
+		// when
+		cache.setRateLimit("other", header(time.Now().Add(time.Hour)))
+
+		// then
+		assert.NotContains(t, cache.rateLimits, "expired")
+		assert.Contains(t, cache.rateLimits, "current")
+		assert.Contains(t, cache.rateLimits, "other")
+	})
+}
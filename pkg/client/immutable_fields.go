@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultRecreateTimeout the default duration to wait for an object to be deleted before it is created again,
+// when an immutable field changed and the `RecreateOnImmutableChange` option is enabled
+const DefaultRecreateTimeout = time.Minute
+
+// recreatePollInterval the interval between two checks that an object to recreate was deleted
+const recreatePollInterval = 100 * time.Millisecond
+
+// ImmutableField a field which cannot be changed once the object was created
+type ImmutableField struct {
+	// Path the path of the field, eg: `[]string{"spec", "selector"}`
+	Path []string
+	// Retain if `true`, then the value of the existing object is retained when the field is not set in the new object
+	// (typically, when the value is allocated or defaulted by the server)
+	Retain bool
+	// Defaulted if `true`, then the server may set some values within the field (eg, the default values of a Pod template),
+	// hence the entries which only exist in the value of the existing object are ignored when the values are compared.
+	// Otherwise (unless `Retain` is `true`), the values of the new and existing objects must be strictly equal.
+	Defaulted bool
+}
+
+func (f ImmutableField) String() string {
+	return strings.Join(f.Path, ".")
+}
+
+var (
+	immutableFieldsLock sync.RWMutex
+	immutableFields     = map[schema.GroupKind][]ImmutableField{
+		{Group: "", Kind: "Service"}: {
+			{Path: []string{"spec", "clusterIP"}, Retain: true},
+			{Path: []string{"spec", "clusterIPs"}, Retain: true},
+		},
+		{Group: "", Kind: "PersistentVolumeClaim"}: {
+			{Path: []string{"spec", "volumeName"}, Retain: true},
+			{Path: []string{"spec", "storageClassName"}, Retain: true},
+		},
+		{Group: "apps", Kind: "Deployment"}: {
+			{Path: []string{"spec", "selector"}},
+		},
+		{Group: "apps", Kind: "StatefulSet"}: {
+			{Path: []string{"spec", "selector"}},
+		},
+		{Group: "batch", Kind: "Job"}: {
+			{Path: []string{"spec", "selector"}, Retain: true},
+			{Path: []string{"spec", "template"}, Defaulted: true},
+		},
+		{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}: {
+			{Path: []string{"roleRef"}},
+		},
+		{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}: {
+			{Path: []string{"roleRef"}},
+		},
+		{Group: "authorization.openshift.io", Kind: "RoleBinding"}: {
+			{Path: []string{"roleRef"}},
+		},
+		{Group: "authorization.openshift.io", Kind: "ClusterRoleBinding"}: {
+			{Path: []string{"roleRef"}},
+		},
+	}
+)
+
+// RegisterImmutableFields registers the given immutable fields for the objects of the given GroupKind, in addition to
+// the ones which were already registered (eg, the `spec.selector` of Deployments, the `roleRef` of RoleBindings, etc.)
+func RegisterImmutableFields(gk schema.GroupKind, fields ...ImmutableField) {
+	immutableFieldsLock.Lock()
+	defer immutableFieldsLock.Unlock()
+	immutableFields[gk] = append(immutableFields[gk], fields...)
+}
+
+func getImmutableFields(gk schema.GroupKind) []ImmutableField {
+	immutableFieldsLock.RLock()
+	defer immutableFieldsLock.RUnlock()
+	return immutableFields[gk]
+}
+
+// retainImmutableFields sets the values of the immutable fields of the 'existing' object into the 'newResource' object, when they are not set
+// in the 'newResource' object and they are registered with `Retain: true`.
+// Returns the immutable fields whose values differ between the 'newResource' object and the 'existing' object
+// (ie, the fields which cannot be updated). The values set by the server within the retained or defaulted fields
+// of the 'existing' object only are ignored (see `ImmutableField.Defaulted`).
+func retainImmutableFields(gvk schema.GroupVersionKind, newResource, existing runtime.Object) ([]ImmutableField, error) {
+	fields := getImmutableFields(gvk.GroupKind())
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	newContent, err := unstructuredContent(newResource)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to convert the resource of kind '%s'", gvk.Kind)
+	}
+	existingContent, err := unstructuredContent(existing)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to convert the resource of kind '%s'", gvk.Kind)
+	}
+	retained := false
+	var changed []ImmutableField
+	for _, field := range fields {
+		existingValue, existingFound, err := unstructured.NestedFieldNoCopy(existingContent, field.Path...)
+		if err != nil || !existingFound {
+			continue
+		}
+		newValue, newFound, err := unstructured.NestedFieldNoCopy(newContent, field.Path...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read the field '%s' of the resource of kind '%s'", field, gvk.Kind)
+		}
+		if !newFound || isEmptyValue(newValue) {
+			if field.Retain {
+				if err := unstructured.SetNestedField(newContent, runtime.DeepCopyJSONValue(existingValue), field.Path...); err != nil {
+					return nil, errors.Wrapf(err, "unable to retain the field '%s' of the resource of kind '%s'", field, gvk.Kind)
+				}
+				retained = true
+			}
+			continue
+		}
+		if field.Retain || field.Defaulted {
+			if !isSubset(newValue, existingValue) {
+				changed = append(changed, field)
+			}
+		} else if !reflect.DeepEqual(newValue, existingValue) {
+			changed = append(changed, field)
+		}
+	}
+	if retained {
+		// unstructured objects were modified in place
+		if _, ok := newResource.(runtime.Unstructured); !ok {
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(newContent, newResource); err != nil {
+				return nil, errors.Wrapf(err, "unable to convert the resource of kind '%s'", gvk.Kind)
+			}
+		}
+	}
+	return changed, nil
+}
+
+// unstructuredContent returns the content of the given object as a map. For unstructured objects, the returned map is the
+// content of the object itself (not a copy).
+func unstructuredContent(obj runtime.Object) (map[string]interface{}, error) {
+	if u, ok := obj.(runtime.Unstructured); ok {
+		return u.UnstructuredContent(), nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+func isEmptyValue(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	default:
+		return false
+	}
+}
+
+// isSubset returns `true` if all the values set in the 'desired' value are the same in the 'actual' value.
+// The entries of maps which only exist in the 'actual' value are ignored, as they are usually set by the server.
+func isSubset(desired, actual interface{}) bool {
+	switch desired := desired.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range desired {
+			if !isSubset(v, actual[k]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok || len(desired) != len(actual) {
+			return false
+		}
+		for i := range desired {
+			if !isSubset(desired[i], actual[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(desired, actual)
+	}
+}
+
+// recreateObject deletes the existing object, waits until it is gone and creates the new object.
+func (c ApplyClient) recreateObject(ctx context.Context, obj, existing client.Object, config applyObjectConfiguration) error {
+	log.Info("recreating object since some immutable fields changed", "kind", obj.GetObjectKind().GroupVersionKind().Kind, "namespace", obj.GetNamespace(), "name", obj.GetName())
+	deleteOpts := []client.DeleteOption{
+		client.PropagationPolicy(metav1.DeletePropagationBackground),
+		client.Preconditions{UID: uidPtr(existing.GetUID())},
+	}
+	if config.dryRun {
+		// the object cannot be created in dry-run mode, as long as it exists
+		return c.Client.Delete(ctx, existing, append(deleteOpts, client.DryRunAll)...)
+	}
+	if err := c.Client.Delete(ctx, existing, deleteOpts...); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "unable to delete the resource '%v' to recreate it", existing)
+	}
+	namespacedName := types.NamespacedName{Namespace: existing.GetNamespace(), Name: existing.GetName()}
+	if err := wait.PollImmediateWithContext(ctx, recreatePollInterval, config.recreateTimeout, func(ctx context.Context) (bool, error) {
+		if err := c.Client.Get(ctx, namespacedName, existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		return false, nil
+	}); err != nil {
+		return errors.Wrapf(err, "unable to wait for the deletion of the resource '%s' to recreate it", namespacedName)
+	}
+	obj.SetResourceVersion("")
+	obj.SetUID("")
+	return c.createObj(ctx, obj, config.owner)
+}
+
+func uidPtr(uid types.UID) *types.UID {
+	if uid == "" {
+		return nil
+	}
+	return &uid
+}
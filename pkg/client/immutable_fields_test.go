@@ -0,0 +1,295 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyObjectWithImmutableFields(t *testing.T) {
+	// given
+	addToScheme(t)
+
+	newDeployment := func(app string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "registration-service",
+				Namespace: "toolchain-host-operator",
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": app},
+				},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{"app": app},
+					},
+				},
+			},
+		}
+	}
+	newRoleBinding := func(role string) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "john-edit",
+				Namespace: "john-dev",
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     role,
+			},
+		}
+	}
+	deploymentName := types.NamespacedName{Namespace: "toolchain-host-operator", Name: "registration-service"}
+
+	t.Run("retain fields", func(t *testing.T) {
+
+		t.Run("should retain volume name and storage class of PersistentVolumeClaims", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			newPVC := func() *corev1.PersistentVolumeClaim {
+				return &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "data",
+						Namespace: "john-dev",
+					},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					},
+				}
+			}
+			_, err := cl.ApplyObject(newPVC())
+			require.NoError(t, err)
+			// the volume is bound and the storage class is defaulted by the server
+			pvc := &corev1.PersistentVolumeClaim{}
+			require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "data"}, pvc))
+			storageClassName := "gp2"
+			pvc.Spec.VolumeName = "pv-123"
+			pvc.Spec.StorageClassName = &storageClassName
+			require.NoError(t, cli.Update(context.TODO(), pvc))
+
+			// when
+			modified := newPVC()
+			modified.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+			result, err := cl.ApplyObject(modified)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyUpdated, result.Operation)
+			require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "data"}, pvc))
+			assert.Equal(t, "pv-123", pvc.Spec.VolumeName)
+			require.NotNil(t, pvc.Spec.StorageClassName)
+			assert.Equal(t, "gp2", *pvc.Spec.StorageClassName)
+			assert.Equal(t, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}, pvc.Spec.AccessModes)
+		})
+
+		t.Run("should retain selector of unstructured Jobs and ignore labels set by the server in the template", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			newJob := func(image string) *unstructured.Unstructured {
+				job := &unstructured.Unstructured{}
+				job.SetAPIVersion("batch/v1")
+				job.SetKind("Job")
+				job.SetNamespace("john-dev")
+				job.SetName("migration")
+				job.SetLabels(map[string]string{"version": image})
+				require.NoError(t, unstructured.SetNestedSlice(job.Object, []interface{}{
+					map[string]interface{}{"name": "migration", "image": image},
+				}, "spec", "template", "spec", "containers"))
+				return job
+			}
+			_, err := cl.ApplyObject(newJob("migration:v1"))
+			require.NoError(t, err)
+			// the selector and the template labels are set by the server
+			job := &batchv1.Job{}
+			require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "migration"}, job))
+			job.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"controller-uid": "123"}}
+			job.Spec.Template.Labels = map[string]string{"controller-uid": "123"}
+			require.NoError(t, cli.Update(context.TODO(), job))
+
+			// when
+			result, err := cl.ApplyObject(newJob("migration:v1"), client.ForceUpdate(true), client.RecreateOnImmutableChange(true))
+
+			// then
+			require.NoError(t, err)
+			assert.NotEqual(t, client.ApplyRecreated, result.Operation)
+			require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "migration"}, job))
+			require.NotNil(t, job.Spec.Selector)
+			assert.Equal(t, map[string]string{"controller-uid": "123"}, job.Spec.Selector.MatchLabels)
+		})
+	})
+
+	t.Run("immutable fields changed", func(t *testing.T) {
+
+		t.Run("should update when recreate is not enabled", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			_, err := cl.ApplyObject(newDeployment("registration-service"))
+			require.NoError(t, err)
+
+			// when
+			result, err := cl.ApplyObject(newDeployment("reg-service"))
+
+			// then
+			require.NoError(t, err) // the fake client does not reject the update
+			assert.Equal(t, client.ApplyUpdated, result.Operation)
+			deployment := &appsv1.Deployment{}
+			require.NoError(t, cli.Get(context.TODO(), deploymentName, deployment))
+			assert.Equal(t, "reg-service", deployment.Spec.Selector.MatchLabels["app"])
+		})
+
+		t.Run("should recreate deployment when selector changed", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			_, err := cl.ApplyObject(newDeployment("registration-service"))
+			require.NoError(t, err)
+			deleted, created := 0, 0
+			cli.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+				deleted++
+				return cli.Client.Delete(ctx, obj, opts...)
+			}
+			cli.MockCreate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+				created++
+				return cli.Client.Create(ctx, obj, opts...)
+			}
+
+			// when
+			result, err := cl.ApplyObject(newDeployment("reg-service"), client.RecreateOnImmutableChange(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyRecreated, result.Operation)
+			assert.True(t, result.CreatedOrUpdated())
+			assert.Equal(t, 1, deleted)
+			assert.Equal(t, 1, created)
+			deployment := &appsv1.Deployment{}
+			require.NoError(t, cli.Get(context.TODO(), deploymentName, deployment))
+			assert.Equal(t, "reg-service", deployment.Spec.Selector.MatchLabels["app"])
+		})
+
+		t.Run("should recreate deployment when a label was removed from the selector", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			deployment := newDeployment("registration-service")
+			deployment.Spec.Selector.MatchLabels["tier"] = "backend"
+			_, err := cl.ApplyObject(deployment)
+			require.NoError(t, err)
+			deleted := 0
+			cli.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+				deleted++
+				return cli.Client.Delete(ctx, obj, opts...)
+			}
+
+			// when
+			result, err := cl.ApplyObject(newDeployment("registration-service"), client.RecreateOnImmutableChange(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyRecreated, result.Operation)
+			assert.Equal(t, 1, deleted)
+			deployment = &appsv1.Deployment{}
+			require.NoError(t, cli.Get(context.TODO(), deploymentName, deployment))
+			assert.Equal(t, map[string]string{"app": "registration-service"}, deployment.Spec.Selector.MatchLabels)
+		})
+
+		t.Run("should not recreate deployment when selector did not change", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			_, err := cl.ApplyObject(newDeployment("registration-service"))
+			require.NoError(t, err)
+			cli.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+				t.Fatal("should not delete the object")
+				return nil
+			}
+			modified := newDeployment("registration-service")
+			replicas := int32(3)
+			modified.Spec.Replicas = &replicas
+
+			// when
+			result, err := cl.ApplyObject(modified, client.RecreateOnImmutableChange(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyUpdated, result.Operation)
+		})
+
+		t.Run("should recreate role binding when role ref changed", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			_, err := cl.ApplyObject(newRoleBinding("edit"))
+			require.NoError(t, err)
+
+			// when
+			result, err := cl.ApplyObject(newRoleBinding("view"), client.RecreateOnImmutableChange(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyRecreated, result.Operation)
+			rb := &rbacv1.RoleBinding{}
+			require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "john-edit"}, rb))
+			assert.Equal(t, "view", rb.RoleRef.Name)
+		})
+
+		t.Run("should recreate object with registered immutable fields", func(t *testing.T) {
+			// given
+			client.RegisterImmutableFields(schema.GroupKind{Group: toolchainv1alpha1.GroupVersion.Group, Kind: "Idler"}, client.ImmutableField{
+				Path: []string{"spec", "timeoutSeconds"},
+			})
+			cl, cli := newClient(t)
+			newIdler := func(timeout int32) *toolchainv1alpha1.Idler {
+				return &toolchainv1alpha1.Idler{
+					ObjectMeta: metav1.ObjectMeta{Name: "john-dev"},
+					Spec:       toolchainv1alpha1.IdlerSpec{TimeoutSeconds: timeout},
+				}
+			}
+			_, err := cl.ApplyObject(newIdler(30))
+			require.NoError(t, err)
+
+			// when
+			result, err := cl.ApplyObject(newIdler(60), client.RecreateOnImmutableChange(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyRecreated, result.Operation)
+			idler := &toolchainv1alpha1.Idler{}
+			require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Name: "john-dev"}, idler))
+			assert.Equal(t, int32(60), idler.Spec.TimeoutSeconds)
+		})
+
+		t.Run("should fail when object is not deleted in time", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			_, err := cl.ApplyObject(newRoleBinding("edit"))
+			require.NoError(t, err)
+			cli.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+				// the object is blocked by a finalizer
+				return nil
+			}
+
+			// when
+			_, err = cl.ApplyObject(newRoleBinding("view"), client.RecreateOnImmutableChange(true), client.RecreateTimeout(300*time.Millisecond))
+
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unable to wait for the deletion of the resource 'john-dev/john-edit' to recreate it")
+			rb := &rbacv1.RoleBinding{}
+			require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Namespace: "john-dev", Name: "john-edit"}, rb))
+			assert.Equal(t, "edit", rb.RoleRef.Name)
+		})
+	})
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	metricsPrefix = "toolchain_apply_client_"
+	// applyErrorResult the value of the `result` label of the metrics when an object could not be applied
+	applyErrorResult = "error"
+)
+
+// The reasons of the Events emitted on the owner of the applied objects
+const (
+	// ApplyCreatedReason the reason of the Event emitted when an object was created
+	ApplyCreatedReason = "Created"
+	// ApplyUpdatedReason the reason of the Event emitted when an object was updated (including its metadata only)
+	ApplyUpdatedReason = "Updated"
+	// ApplyRecreatedReason the reason of the Event emitted when an object was deleted and created again
+	ApplyRecreatedReason = "Recreated"
+	// ApplyFailedReason the reason of the Event emitted when an object could not be applied
+	ApplyFailedReason = "ApplyFailed"
+)
+
+var (
+	// applyOperationsTotal the number of objects applied, by GVK, result (ie, the operation or `error`) and cluster name
+	applyOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "operations_total",
+		Help: "Number of objects applied by the apply client, by GVK, result and cluster",
+	}, []string{"gvk", "result", "cluster"})
+
+	// applyDurationSeconds the duration of the apply of an object (including the retries), by GVK, result and cluster name
+	applyDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    metricsPrefix + "operation_duration_seconds",
+		Help:    "Duration of the apply of an object by the apply client, by GVK, result and cluster",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"gvk", "result", "cluster"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(applyOperationsTotal, applyDurationSeconds)
+}
+
+// EventRecorder sets the recorder used to emit Events on the owner of the applied objects (see `SetOwner`),
+// when they are created, updated, recreated or could not be applied (default: no Events are emitted)
+func EventRecorder(recorder record.EventRecorder) ApplyClientOption {
+	return func(c *ApplyClient) {
+		c.eventRecorder = recorder
+	}
+}
+
+// ClusterName sets the name of the cluster on which the objects are applied,
+// which is used as the `cluster` label of the metrics (default: empty)
+func ClusterName(name string) ApplyClientOption {
+	return func(c *ApplyClient) {
+		c.clusterName = name
+	}
+}
+
+// recordApply records the metrics and emits the Event (if an EventRecorder is set) of the apply of the given object.
+// Nothing is recorded in dry-run mode.
+func (c ApplyClient) recordApply(obj client.Object, config applyObjectConfiguration, result ApplyResult, err error, duration time.Duration) {
+	if config.dryRun {
+		return
+	}
+	gvk := result.GVK
+	resultLabel := string(result.Operation)
+	if err != nil {
+		gvk = c.gvkForObject(obj)
+		resultLabel = applyErrorResult
+	}
+	applyOperationsTotal.WithLabelValues(gvkLabel(gvk), resultLabel, c.clusterName).Inc()
+	applyDurationSeconds.WithLabelValues(gvkLabel(gvk), resultLabel, c.clusterName).Observe(duration.Seconds())
+
+	if c.eventRecorder == nil || config.owner == nil {
+		return
+	}
+	owner, ok := config.owner.(runtime.Object)
+	if !ok {
+		return
+	}
+	name := obj.GetName()
+	if obj.GetNamespace() != "" {
+		name = obj.GetNamespace() + "/" + name
+	}
+	if err != nil {
+		c.eventRecorder.Eventf(owner, corev1.EventTypeWarning, ApplyFailedReason, "unable to apply %s '%s': %s", gvk.Kind, name, err.Error())
+		return
+	}
+	switch result.Operation {
+	case ApplyCreated:
+		c.eventRecorder.Eventf(owner, corev1.EventTypeNormal, ApplyCreatedReason, "%s '%s' was created", gvk.Kind, name)
+	case ApplyUpdated, ApplyMetadataUpdated:
+		c.eventRecorder.Eventf(owner, corev1.EventTypeNormal, ApplyUpdatedReason, "%s '%s' was updated", gvk.Kind, name)
+	case ApplyRecreated:
+		c.eventRecorder.Eventf(owner, corev1.EventTypeNormal, ApplyRecreatedReason, "%s '%s' was recreated", gvk.Kind, name)
+	}
+}
+
+// gvkLabel returns the value of the `gvk` label of the metrics for the given GroupVersionKind, eg: `apps/v1/Deployment`
+func gvkLabel(gvk schema.GroupVersionKind) string {
+	return fmt.Sprintf("%s/%s", gvk.GroupVersion().String(), gvk.Kind)
+}
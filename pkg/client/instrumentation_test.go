@@ -0,0 +1,160 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	. "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+func TestApplyClientInstrumentation(t *testing.T) {
+	// given
+	addToScheme(t)
+	newOwner := func() *toolchainv1alpha1.UserSignup {
+		return &toolchainv1alpha1.UserSignup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "john",
+				Namespace: "toolchain-host-operator",
+				UID:       "123",
+			},
+		}
+	}
+
+	t.Run("should record metrics and emit events", func(t *testing.T) {
+		// given
+		recorder := record.NewFakeRecorder(10)
+		cl := client.NewApplyClient(NewFakeClient(t), client.EventRecorder(recorder), client.ClusterName("member-events"))
+
+		// when
+		created, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"param": "first-value"}), client.SetOwner(newOwner()))
+		require.NoError(t, err)
+		unchanged, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"param": "first-value"}), client.SetOwner(newOwner()))
+		require.NoError(t, err)
+		updated, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"param": "second-value"}), client.SetOwner(newOwner()))
+		require.NoError(t, err)
+
+		// then
+		assert.Equal(t, client.ApplyCreated, created.Operation)
+		assert.Equal(t, client.ApplyUnchanged, unchanged.Operation)
+		assert.Equal(t, client.ApplyUpdated, updated.Operation)
+		assert.Equal(t, 1.0, applyOperationsCount(t, "v1/ConfigMap", "created", "member-events"))
+		assert.Equal(t, 1.0, applyOperationsCount(t, "v1/ConfigMap", "unchanged", "member-events"))
+		assert.Equal(t, 1.0, applyOperationsCount(t, "v1/ConfigMap", "updated", "member-events"))
+		assert.Equal(t, uint64(3), applyDurationsCount(t, "v1/ConfigMap", "member-events"))
+		// no Event is emitted when the object is unchanged
+		assertEvents(t, recorder,
+			"Normal Created ConfigMap 'toolchain-host-operator/registration-service' was created",
+			"Normal Updated ConfigMap 'toolchain-host-operator/registration-service' was updated")
+	})
+
+	t.Run("should record failure", func(t *testing.T) {
+		// given
+		recorder := record.NewFakeRecorder(10)
+		fakeClient := NewFakeClient(t)
+		fakeClient.MockCreate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+			return fmt.Errorf("unable to create")
+		}
+		cl := client.NewApplyClient(fakeClient, client.EventRecorder(recorder), client.ClusterName("member-failure"))
+
+		// when
+		_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"param": "first-value"}), client.SetOwner(newOwner()))
+
+		// then
+		require.Error(t, err)
+		assert.Equal(t, 1.0, applyOperationsCount(t, "v1/ConfigMap", "error", "member-failure"))
+		assertEvents(t, recorder,
+			"Warning ApplyFailed unable to apply ConfigMap 'toolchain-host-operator/registration-service': unable to create")
+	})
+
+	t.Run("should not emit events without owner", func(t *testing.T) {
+		// given
+		recorder := record.NewFakeRecorder(10)
+		cl := client.NewApplyClient(NewFakeClient(t), client.EventRecorder(recorder), client.ClusterName("member-no-owner"))
+
+		// when
+		_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"param": "first-value"}))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1.0, applyOperationsCount(t, "v1/ConfigMap", "created", "member-no-owner"))
+		assertEvents(t, recorder)
+	})
+
+	t.Run("should not record anything in dry-run mode", func(t *testing.T) {
+		// given
+		recorder := record.NewFakeRecorder(10)
+		cl := client.NewApplyClient(NewFakeClient(t), client.EventRecorder(recorder), client.ClusterName("member-dry-run"))
+
+		// when
+		_, err := cl.DryRun([]runtimeclient.Object{newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"param": "first-value"})}, nil, client.DryRunServer)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, applyOperationsCount(t, "v1/ConfigMap", "created", "member-dry-run"))
+		assertEvents(t, recorder)
+	})
+}
+
+func applyOperationsCount(t *testing.T, gvk, result, cluster string) float64 {
+	families, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "toolchain_apply_client_operations_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if hasLabels(metric.GetLabel(), map[string]string{"gvk": gvk, "result": result, "cluster": cluster}) {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func applyDurationsCount(t *testing.T, gvk, cluster string) uint64 {
+	families, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	var count uint64
+	for _, family := range families {
+		if family.GetName() != "toolchain_apply_client_operation_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if hasLabels(metric.GetLabel(), map[string]string{"gvk": gvk, "cluster": cluster}) {
+				count += metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return count
+}
+
+func hasLabels(labels []*dto.LabelPair, expected map[string]string) bool {
+	matched := 0
+	for _, label := range labels {
+		if value, ok := expected[label.GetName()]; ok {
+			if value != label.GetValue() {
+				return false
+			}
+			matched++
+		}
+	}
+	return matched == len(expected)
+}
+
+func assertEvents(t *testing.T, recorder *record.FakeRecorder, expected ...string) {
+	var actual []string
+	for len(recorder.Events) > 0 {
+		actual = append(actual, <-recorder.Events)
+	}
+	assert.Equal(t, expected, actual)
+}
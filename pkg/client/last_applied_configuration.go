@@ -0,0 +1,141 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ConfigurationEncoding the encoding of the last applied configuration stored in the resource annotations
+type ConfigurationEncoding string
+
+const (
+	// PlainConfigurationEncoding the last applied configuration is stored as plain JSON
+	PlainConfigurationEncoding ConfigurationEncoding = "plain"
+	// GzipConfigurationEncoding the last applied configuration is stored as gzipped and base64-encoded JSON,
+	// prefixed by `gzip.v1:`. This reduces the size of the annotation for large resources (eg, ConfigMaps and Templates).
+	GzipConfigurationEncoding ConfigurationEncoding = "gzip"
+	// HashConfigurationEncoding only the SHA-256 hash of the JSON of the last applied configuration is stored, prefixed by `sha256.v1:`.
+	// This is enough to detect if the resource changed, but the fields which are removed from the resource cannot be removed
+	// from the existing resource when the `ThreeWayMergePatch` option is used.
+	HashConfigurationEncoding ConfigurationEncoding = "hash"
+)
+
+const (
+	gzipConfigurationPrefix = "gzip.v1:"
+	hashConfigurationPrefix = "sha256.v1:"
+)
+
+// LastAppliedConfigurationEncoding sets the encoding of the last applied configuration stored
+// in the resource annotations (default: `PlainConfigurationEncoding`).
+// The annotations which were stored with any other encoding can still be read, so that the encoding can be changed at any time.
+func LastAppliedConfigurationEncoding(encoding ConfigurationEncoding) ApplyObjectOption {
+	return func(config *applyObjectConfiguration) {
+		config.configurationEncoding = encoding
+	}
+}
+
+// encodeConfiguration returns the value of the last applied configuration annotation for the given JSON content, using the given encoding
+func encodeConfiguration(content []byte, encoding ConfigurationEncoding) (string, error) {
+	switch encoding {
+	case PlainConfigurationEncoding, "":
+		return string(content), nil
+	case GzipConfigurationEncoding:
+		buf := &bytes.Buffer{}
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(content); err != nil {
+			return "", err
+		}
+		if err := w.Close(); err != nil {
+			return "", err
+		}
+		return gzipConfigurationPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+	case HashConfigurationEncoding:
+		return hashConfigurationPrefix + hashConfiguration(content), nil
+	default:
+		return "", fmt.Errorf("unknown last applied configuration encoding: '%s'", encoding)
+	}
+}
+
+// decodeConfiguration returns the JSON content of the given last applied configuration annotation, regardless of its encoding.
+// Returns `nil, nil` if the annotation only contains the hash of the configuration.
+func decodeConfiguration(annotation string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(annotation, hashConfigurationPrefix):
+		return nil, nil
+	case strings.HasPrefix(annotation, gzipConfigurationPrefix):
+		compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(annotation, gzipConfigurationPrefix))
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to decode the last applied configuration")
+		}
+		r, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to decompress the last applied configuration")
+		}
+		defer r.Close()
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to decompress the last applied configuration")
+		}
+		return content, nil
+	default:
+		return []byte(annotation), nil
+	}
+}
+
+func hashConfiguration(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// sameConfiguration returns `true` if the given new configuration (whose content and encoded annotation value are given)
+// is the same as the existing last applied configuration annotation, regardless of the encoding of the latter.
+func sameConfiguration(newContent []byte, newAnnotation, existingAnnotation string) bool {
+	if newAnnotation == existingAnnotation {
+		return true
+	}
+	if newContent == nil || existingAnnotation == "" {
+		return false
+	}
+	if strings.HasPrefix(existingAnnotation, hashConfigurationPrefix) {
+		return strings.TrimPrefix(existingAnnotation, hashConfigurationPrefix) == hashConfiguration(newContent)
+	}
+	existingContent, err := decodeConfiguration(existingAnnotation)
+	if err != nil {
+		log.Error(err, "unable to read the last applied configuration")
+		return false
+	}
+	var newConfig, existingConfig interface{}
+	if err := json.Unmarshal(newContent, &newConfig); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(existingContent, &existingConfig); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(newConfig, existingConfig)
+}
+
+// newConfigurationAnnotation returns the JSON content of the given resource, and the value of the last applied configuration annotation
+// for this content, using the given encoding
+func newConfigurationAnnotation(newResource runtime.Object, encoding ConfigurationEncoding) ([]byte, string, error) {
+	content, err := marshalObjectContent(newResource)
+	if err != nil {
+		log.Error(err, "unable to marshal the object", "object", newResource)
+		return nil, fmt.Sprintf("%v", newResource), nil
+	}
+	annotation, err := encodeConfiguration(content, encoding)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, annotation, nil
+}
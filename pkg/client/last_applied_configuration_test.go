@@ -0,0 +1,171 @@
+package client_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestLastAppliedConfigurationEncoding(t *testing.T) {
+	// given
+	addToScheme(t)
+	namespacedName := types.NamespacedName{Namespace: "toolchain-host-operator", Name: "registration-service"}
+	lastApplied := func(t *testing.T, cl *client.ApplyClient) string {
+		cm := &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), namespacedName, cm))
+		return cm.Annotations[client.LastAppliedConfigurationAnnotationKey]
+	}
+	data := map[string]string{"first-param": "first-value"}
+
+	t.Run("gzip", func(t *testing.T) {
+
+		t.Run("should store compressed configuration", func(t *testing.T) {
+			// given
+			cl, _ := newClient(t)
+			largeData := map[string]string{}
+			for i := 0; i < 1000; i++ {
+				largeData[fmt.Sprintf("param-%d", i)] = strings.Repeat("value", 10)
+			}
+
+			// when
+			_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", largeData), client.LastAppliedConfigurationEncoding(client.GzipConfigurationEncoding))
+
+			// then
+			require.NoError(t, err)
+			annotation := lastApplied(t, cl)
+			require.True(t, strings.HasPrefix(annotation, "gzip.v1:"))
+			compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(annotation, "gzip.v1:"))
+			require.NoError(t, err)
+			r, err := gzip.NewReader(bytes.NewReader(compressed))
+			require.NoError(t, err)
+			content, err := io.ReadAll(r)
+			require.NoError(t, err)
+			cm := &corev1.ConfigMap{}
+			require.NoError(t, json.Unmarshal(content, cm))
+			assert.Equal(t, largeData, cm.Data)
+			assert.Less(t, len(annotation), len(content)/5)
+		})
+
+		t.Run("should remove fields using three-way merge patch", func(t *testing.T) {
+			// given
+			cl, _ := newClient(t)
+			_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value", "second-param": "second-value"}),
+				client.LastAppliedConfigurationEncoding(client.GzipConfigurationEncoding), client.ThreeWayMergePatch(true))
+			require.NoError(t, err)
+
+			// when
+			_, err = cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", data),
+				client.LastAppliedConfigurationEncoding(client.GzipConfigurationEncoding), client.ThreeWayMergePatch(true))
+
+			// then
+			require.NoError(t, err)
+			cm := &corev1.ConfigMap{}
+			require.NoError(t, cl.Get(context.TODO(), namespacedName, cm))
+			assert.Equal(t, data, cm.Data)
+		})
+	})
+
+	t.Run("hash", func(t *testing.T) {
+
+		t.Run("should store the hash of the configuration", func(t *testing.T) {
+			// given
+			cl, _ := newClient(t)
+
+			// when
+			_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", data), client.LastAppliedConfigurationEncoding(client.HashConfigurationEncoding))
+
+			// then
+			require.NoError(t, err)
+			content, err := json.Marshal(newConfigMap("toolchain-host-operator", "registration-service", data))
+			require.NoError(t, err)
+			hash := sha256.Sum256(content)
+			assert.Equal(t, "sha256.v1:"+hex.EncodeToString(hash[:]), lastApplied(t, cl))
+		})
+
+		t.Run("should detect changes", func(t *testing.T) {
+			// given
+			cl, _ := newClient(t)
+			_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", data), client.LastAppliedConfigurationEncoding(client.HashConfigurationEncoding))
+			require.NoError(t, err)
+
+			// when
+			unchanged, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", data), client.LastAppliedConfigurationEncoding(client.HashConfigurationEncoding))
+			require.NoError(t, err)
+			updated, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "second-value"}), client.LastAppliedConfigurationEncoding(client.HashConfigurationEncoding))
+			require.NoError(t, err)
+
+			// then
+			assert.Equal(t, client.ApplyUnchanged, unchanged.Operation)
+			assert.Equal(t, client.ApplyUpdated, updated.Operation)
+		})
+
+		t.Run("should not remove fields using three-way merge patch", func(t *testing.T) {
+			// given
+			cl, _ := newClient(t)
+			_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value", "second-param": "second-value"}),
+				client.LastAppliedConfigurationEncoding(client.HashConfigurationEncoding), client.ThreeWayMergePatch(true))
+			require.NoError(t, err)
+
+			// when
+			_, err = cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", data),
+				client.LastAppliedConfigurationEncoding(client.HashConfigurationEncoding), client.ThreeWayMergePatch(true))
+
+			// then
+			require.NoError(t, err)
+			cm := &corev1.ConfigMap{}
+			require.NoError(t, cl.Get(context.TODO(), namespacedName, cm))
+			// there is no original configuration to compute the fields to remove
+			assert.Equal(t, map[string]string{"first-param": "first-value", "second-param": "second-value"}, cm.Data)
+		})
+	})
+
+	t.Run("should read configuration stored with another encoding", func(t *testing.T) {
+		encodings := []client.ConfigurationEncoding{client.PlainConfigurationEncoding, client.GzipConfigurationEncoding, client.HashConfigurationEncoding}
+		for _, before := range encodings {
+			for _, after := range encodings {
+				t.Run(fmt.Sprintf("from %s to %s", before, after), func(t *testing.T) {
+					// given
+					cl, _ := newClient(t)
+					_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", data), client.LastAppliedConfigurationEncoding(before))
+					require.NoError(t, err)
+					annotation := lastApplied(t, cl)
+
+					// when
+					result, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", data), client.LastAppliedConfigurationEncoding(after))
+
+					// then
+					require.NoError(t, err)
+					assert.Equal(t, client.ApplyUnchanged, result.Operation)
+					// the annotation is not updated since the configuration did not change
+					assert.Equal(t, annotation, lastApplied(t, cl))
+				})
+			}
+		}
+	})
+
+	t.Run("should fail with unknown encoding", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+
+		// when
+		_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", data), client.LastAppliedConfigurationEncoding("unknown"))
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown last applied configuration encoding: 'unknown'")
+	})
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AddFinalizer adds the given finalizer to the object (if missing).
+// The object is patched with an optimistic lock on its resource version, and read again and patched again on conflict.
+// Returns `true` if the object was changed, in which case the given object is updated with the response of the server
+func AddFinalizer(ctx context.Context, cl client.Client, obj client.Object, finalizer string) (bool, error) {
+	return patchMetadata(ctx, cl, obj, func(obj client.Object) bool {
+		for _, f := range obj.GetFinalizers() {
+			if f == finalizer {
+				return false
+			}
+		}
+		obj.SetFinalizers(append(obj.GetFinalizers(), finalizer))
+		return true
+	})
+}
+
+// RemoveFinalizer removes the given finalizer from the object (if present).
+// The object is patched with an optimistic lock on its resource version, and read again and patched again on conflict.
+// Returns `true` if the object was changed, in which case the given object is updated with the response of the server
+func RemoveFinalizer(ctx context.Context, cl client.Client, obj client.Object, finalizer string) (bool, error) {
+	return patchMetadata(ctx, cl, obj, func(obj client.Object) bool {
+		finalizers := make([]string, 0, len(obj.GetFinalizers()))
+		for _, f := range obj.GetFinalizers() {
+			if f != finalizer {
+				finalizers = append(finalizers, f)
+			}
+		}
+		if len(finalizers) == len(obj.GetFinalizers()) {
+			return false
+		}
+		obj.SetFinalizers(finalizers)
+		return true
+	})
+}
+
+// PatchLabels merges the given labels into the labels of the object.
+// The object is patched with an optimistic lock on its resource version, and read again and patched again on conflict.
+// Returns `true` if the object was changed, in which case the given object is updated with the response of the server
+func PatchLabels(ctx context.Context, cl client.Client, obj client.Object, labels map[string]string) (bool, error) {
+	return patchMetadata(ctx, cl, obj, func(obj client.Object) bool {
+		merged, changed := mergeEntries(obj.GetLabels(), labels)
+		obj.SetLabels(merged)
+		return changed
+	})
+}
+
+// RemoveLabels removes the labels with the given keys from the object.
+// The object is patched with an optimistic lock on its resource version, and read again and patched again on conflict.
+// Returns `true` if the object was changed, in which case the given object is updated with the response of the server
+func RemoveLabels(ctx context.Context, cl client.Client, obj client.Object, keys ...string) (bool, error) {
+	return patchMetadata(ctx, cl, obj, func(obj client.Object) bool {
+		remaining, changed := removeEntries(obj.GetLabels(), keys)
+		obj.SetLabels(remaining)
+		return changed
+	})
+}
+
+// PatchAnnotations merges the given annotations into the annotations of the object.
+// The object is patched with an optimistic lock on its resource version, and read again and patched again on conflict.
+// Returns `true` if the object was changed, in which case the given object is updated with the response of the server
+func PatchAnnotations(ctx context.Context, cl client.Client, obj client.Object, annotations map[string]string) (bool, error) {
+	return patchMetadata(ctx, cl, obj, func(obj client.Object) bool {
+		merged, changed := mergeEntries(obj.GetAnnotations(), annotations)
+		obj.SetAnnotations(merged)
+		return changed
+	})
+}
+
+// RemoveAnnotations removes the annotations with the given keys from the object.
+// The object is patched with an optimistic lock on its resource version, and read again and patched again on conflict.
+// Returns `true` if the object was changed, in which case the given object is updated with the response of the server
+func RemoveAnnotations(ctx context.Context, cl client.Client, obj client.Object, keys ...string) (bool, error) {
+	return patchMetadata(ctx, cl, obj, func(obj client.Object) bool {
+		remaining, changed := removeEntries(obj.GetAnnotations(), keys)
+		obj.SetAnnotations(remaining)
+		return changed
+	})
+}
+
+// patchMetadata applies the given mutation on the object and sends the changes to the server as a JSON merge patch
+// which includes the resource version of the object, so that the patch is rejected if the object was modified in the meantime.
+// In that case, the object is read again and the mutation is applied again, until the patch succeeds or the mutation is no-op.
+// Returns `true` if the object was changed, and updates the given object with the response of the server.
+// If the patch fails, then the given object is restored to the last version read from the server.
+func patchMetadata(ctx context.Context, cl client.Client, obj client.Object, mutate func(obj client.Object) bool) (bool, error) {
+	changed := false
+	attempt := 0
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		attempt++
+		if attempt > 1 {
+			if err := getLatest(ctx, cl, obj); err != nil {
+				return err
+			}
+		}
+		base := obj.DeepCopyObject().(client.Object)
+		if !mutate(obj) {
+			return nil
+		}
+		if err := cl.Patch(ctx, obj, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{})); err != nil {
+			// do not leave the mutation on the object, since it was not persisted
+			setObject(obj, base)
+			return err
+		}
+		changed = true
+		return nil
+	})
+	if err != nil {
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		return false, errors.Wrapf(err, "unable to patch the metadata of the resource of kind: %s, namespace: '%s', name: '%s'", gvk.Kind, obj.GetNamespace(), obj.GetName())
+	}
+	return changed, nil
+}
+
+// getLatest reads the latest version of the given object from the server. The object is read into a new instance
+// (which is then copied into the given object), so that no stale entry of the maps of the given object is kept.
+func getLatest(ctx context.Context, cl client.Client, obj client.Object) error {
+	latest := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(client.Object)
+	latest.GetObjectKind().SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(obj), latest); err != nil {
+		return err
+	}
+	setObject(obj, latest)
+	return nil
+}
+
+// setObject copies the content of the given source object into the given object (both must be of the same type)
+func setObject(obj, source client.Object) {
+	reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(source).Elem())
+}
+
+// mergeEntries returns the given entries merged with the new ones, and `true` if any entry was added or changed
+func mergeEntries(entries, newEntries map[string]string) (map[string]string, bool) {
+	changed := false
+	for key, value := range newEntries {
+		if existing, found := entries[key]; found && existing == value {
+			continue
+		}
+		if entries == nil {
+			entries = make(map[string]string, len(newEntries))
+		}
+		entries[key] = value
+		changed = true
+	}
+	return entries, changed
+}
+
+// removeEntries returns the given entries without the ones with the given keys, and `true` if any entry was removed
+func removeEntries(entries map[string]string, keys []string) (map[string]string, bool) {
+	changed := false
+	for _, key := range keys {
+		if _, found := entries[key]; found {
+			delete(entries, key)
+			changed = true
+		}
+	}
+	return entries, changed
+}
@@ -0,0 +1,289 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	. "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestMetadataPatch(t *testing.T) {
+	// given
+	addToScheme(t)
+	namespacedName := types.NamespacedName{Namespace: "toolchain-host-operator", Name: "john"}
+	newSpace := func() *toolchainv1alpha1.Space {
+		return &toolchainv1alpha1.Space{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        namespacedName.Name,
+				Namespace:   namespacedName.Namespace,
+				Labels:      map[string]string{"toolchain.dev.openshift.com/owner": "john"},
+				Annotations: map[string]string{"toolchain.dev.openshift.com/tier": "base"},
+				Finalizers:  []string{"finalizer.toolchain.dev.openshift.com"},
+			},
+		}
+	}
+	// getSpace returns the Space from the given client
+	getSpace := func(t *testing.T, cl runtimeclient.Client) *toolchainv1alpha1.Space {
+		space := &toolchainv1alpha1.Space{}
+		require.NoError(t, cl.Get(context.TODO(), namespacedName, space))
+		return space
+	}
+	// failOnPatch makes the test fail if the object is patched
+	failOnPatch := func(t *testing.T, cl *FakeClient) {
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			t.Fatal("should not patch the object")
+			return nil
+		}
+	}
+
+	t.Run("finalizers", func(t *testing.T) {
+
+		t.Run("should add finalizer", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+			space := getSpace(t, cl)
+
+			// when
+			changed, err := client.AddFinalizer(context.TODO(), cl, space, "other.toolchain.dev.openshift.com")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, changed)
+			assert.Equal(t, []string{"finalizer.toolchain.dev.openshift.com", "other.toolchain.dev.openshift.com"}, getSpace(t, cl).Finalizers)
+			assert.Equal(t, getSpace(t, cl).ResourceVersion, space.ResourceVersion)
+		})
+
+		t.Run("should not add existing finalizer", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+			failOnPatch(t, cl)
+
+			// when
+			changed, err := client.AddFinalizer(context.TODO(), cl, getSpace(t, cl), "finalizer.toolchain.dev.openshift.com")
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, changed)
+		})
+
+		t.Run("should remove finalizer", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+
+			// when
+			changed, err := client.RemoveFinalizer(context.TODO(), cl, getSpace(t, cl), "finalizer.toolchain.dev.openshift.com")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, changed)
+			assert.Empty(t, getSpace(t, cl).Finalizers)
+		})
+
+		t.Run("should not remove missing finalizer", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+			failOnPatch(t, cl)
+
+			// when
+			changed, err := client.RemoveFinalizer(context.TODO(), cl, getSpace(t, cl), "other.toolchain.dev.openshift.com")
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, changed)
+		})
+	})
+
+	t.Run("labels", func(t *testing.T) {
+
+		t.Run("should add and update labels", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+
+			// when
+			changed, err := client.PatchLabels(context.TODO(), cl, getSpace(t, cl), map[string]string{
+				"toolchain.dev.openshift.com/owner": "johnny",
+				"toolchain.dev.openshift.com/state": "ready",
+			})
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, changed)
+			assert.Equal(t, map[string]string{
+				"toolchain.dev.openshift.com/owner": "johnny",
+				"toolchain.dev.openshift.com/state": "ready",
+			}, getSpace(t, cl).Labels)
+		})
+
+		t.Run("should not patch labels when unchanged", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+			failOnPatch(t, cl)
+
+			// when
+			changed, err := client.PatchLabels(context.TODO(), cl, getSpace(t, cl), map[string]string{"toolchain.dev.openshift.com/owner": "john"})
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, changed)
+		})
+
+		t.Run("should remove labels", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+
+			// when
+			changed, err := client.RemoveLabels(context.TODO(), cl, getSpace(t, cl), "toolchain.dev.openshift.com/owner", "unknown")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, changed)
+			assert.Empty(t, getSpace(t, cl).Labels)
+		})
+
+		t.Run("should not remove missing labels", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+			failOnPatch(t, cl)
+
+			// when
+			changed, err := client.RemoveLabels(context.TODO(), cl, getSpace(t, cl), "unknown")
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, changed)
+		})
+	})
+
+	t.Run("annotations", func(t *testing.T) {
+
+		t.Run("should add annotations", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+
+			// when
+			changed, err := client.PatchAnnotations(context.TODO(), cl, getSpace(t, cl), map[string]string{"toolchain.dev.openshift.com/last-updated": "now"})
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, changed)
+			assert.Equal(t, map[string]string{
+				"toolchain.dev.openshift.com/tier":         "base",
+				"toolchain.dev.openshift.com/last-updated": "now",
+			}, getSpace(t, cl).Annotations)
+		})
+
+		t.Run("should remove annotations", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+
+			// when
+			changed, err := client.RemoveAnnotations(context.TODO(), cl, getSpace(t, cl), "toolchain.dev.openshift.com/tier")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, changed)
+			assert.Empty(t, getSpace(t, cl).Annotations)
+		})
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+
+		t.Run("should retry with latest version when object was modified in the meantime", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+			stale := getSpace(t, cl)
+			// another controller added a finalizer in the meantime
+			latest := getSpace(t, cl)
+			latest.Finalizers = append(latest.Finalizers, "other.toolchain.dev.openshift.com")
+			require.NoError(t, cl.Update(context.TODO(), latest))
+
+			// when
+			changed, err := client.AddFinalizer(context.TODO(), cl, stale, "third.toolchain.dev.openshift.com")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, changed)
+			assert.Equal(t, []string{"finalizer.toolchain.dev.openshift.com", "other.toolchain.dev.openshift.com", "third.toolchain.dev.openshift.com"},
+				getSpace(t, cl).Finalizers)
+		})
+
+		t.Run("should not patch when latest version already has the change", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+			stale := getSpace(t, cl)
+			latest := getSpace(t, cl)
+			latest.Labels["toolchain.dev.openshift.com/state"] = "ready"
+			require.NoError(t, cl.Update(context.TODO(), latest))
+
+			// when
+			changed, err := client.PatchLabels(context.TODO(), cl, stale, map[string]string{"toolchain.dev.openshift.com/state": "ready"})
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, changed)
+			assert.Equal(t, latest.ResourceVersion, stale.ResourceVersion)
+		})
+
+		t.Run("should fail on other errors", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+			cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+				return fmt.Errorf("mock error")
+			}
+
+			space := getSpace(t, cl)
+
+			// when
+			changed, err := client.RemoveFinalizer(context.TODO(), cl, space, "finalizer.toolchain.dev.openshift.com")
+
+			// then
+			require.EqualError(t, err, "unable to patch the metadata of the resource of kind: Space, namespace: 'toolchain-host-operator', name: 'john': mock error")
+			assert.False(t, changed)
+			// the object is left unchanged
+			assert.Equal(t, []string{"finalizer.toolchain.dev.openshift.com"}, space.Finalizers)
+		})
+
+		t.Run("should restore the object on other errors", func(t *testing.T) {
+			// given
+			cl := NewFakeClient(t, newSpace())
+			cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+				return fmt.Errorf("mock error")
+			}
+			space := getSpace(t, cl)
+
+			// when
+			_, err := client.PatchLabels(context.TODO(), cl, space, map[string]string{"toolchain.dev.openshift.com/state": "ready"})
+
+			// then
+			require.Error(t, err)
+			assert.Equal(t, getSpace(t, cl), space)
+		})
+	})
+
+	t.Run("should patch unstructured object", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t, newSpace())
+		stale := &unstructured.Unstructured{}
+		stale.SetAPIVersion("toolchain.dev.openshift.com/v1alpha1")
+		stale.SetKind("Space")
+		require.NoError(t, cl.Get(context.TODO(), namespacedName, stale))
+		require.NoError(t, cl.Update(context.TODO(), getSpace(t, cl)))
+
+		// when
+		changed, err := client.PatchLabels(context.TODO(), cl, stale, map[string]string{"toolchain.dev.openshift.com/state": "ready"})
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, changed)
+		assert.Equal(t, "ready", getSpace(t, cl).Labels["toolchain.dev.openshift.com/state"])
+	})
+}
@@ -12,23 +12,54 @@ import (
 // The resulting sorted array is then returned.
 // This function is important for write predictable and reliable tests
 func SortObjectsByName(objects []runtimeclient.Object) []runtimeclient.Object {
-	names := make([]string, len(objects))
+	type namedObject struct {
+		name   string
+		object runtimeclient.Object
+	}
+	namedObjects := make([]namedObject, len(objects))
 	for i, object := range objects {
-		names[i] = fmt.Sprintf("%s,%s", object.GetNamespace(), object.GetName())
+		namedObjects[i] = namedObject{
+			name:   fmt.Sprintf("%s,%s", object.GetNamespace(), object.GetName()),
+			object: object,
+		}
 	}
-	sort.Strings(names)
+	sort.SliceStable(namedObjects, func(i, j int) bool {
+		return namedObjects[i].name < namedObjects[j].name
+	})
 	sortedObjects := make([]runtimeclient.Object, len(objects))
-	for i, name := range names {
-		for _, object := range objects {
-			if fmt.Sprintf("%s,%s", object.GetNamespace(), object.GetName()) == name {
-				sortedObjects[i] = object
-				break
-			}
-		}
+	for i, namedObject := range namedObjects {
+		sortedObjects[i] = namedObject.object
 	}
 	return sortedObjects
 }
 
+// kindPriorities the priorities of the kinds of objects, when applying them. The objects with a lower priority value
+// are applied first, so that the objects they depend on (namespaces, CRDs, RBAC, config) already exist.
+// The kinds which are not listed here (eg, the workloads) are applied last.
+var kindPriorities = map[string]int{
+	"Namespace":                0,
+	"CustomResourceDefinition": 1,
+	"ServiceAccount":           2,
+	"ClusterRole":              2,
+	"ClusterRoleBinding":       2,
+	"Role":                     2,
+	"RoleBinding":              2,
+	"ConfigMap":                3,
+	"Secret":                   3,
+	"LimitRange":               3,
+	"ResourceQuota":            3,
+}
+
+// lowestKindPriority the priority of the kinds which are not listed in the kindPriorities
+const lowestKindPriority = 4
+
+func kindPriority(kind string) int {
+	if priority, found := kindPriorities[kind]; found {
+		return priority
+	}
+	return lowestKindPriority
+}
+
 // SameGVKandName returns `true` if both objects have the same GroupVersionKind and Name, `false` otherwise
 func SameGVKandName(a, b runtimeclient.Object) bool {
 	return a.GetObjectKind().GroupVersionKind() == b.GetObjectKind().GroupVersionKind() &&
@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// InventoryLabelKey the key of the label set on all the objects applied with an inventory. The value of the label is the name of the inventory.
+	InventoryLabelKey = "toolchain.dev.openshift.com/inventory"
+	// PruneProtectionAnnotationKey the key of the annotation to set (with the `true` value) on an object to make sure it is never pruned
+	PruneProtectionAnnotationKey = "toolchain.dev.openshift.com/prune-protected"
+	// inventoryDataKey the key of the data in the inventory ConfigMap which contains the list of applied objects
+	inventoryDataKey = "inventory"
+)
+
+// Inventory keeps track of the objects which were applied under the same key, so that the objects which are not part of the desired set
+// anymore can be pruned. The inventory is stored in a ConfigMap whose namespace and name are given by the Namespace and Name fields.
+type Inventory struct {
+	// Namespace the namespace of the ConfigMap which stores the inventory
+	Namespace string
+	// Name the name of the ConfigMap which stores the inventory. It is also used as the inventory key,
+	// ie, as the value of the `InventoryLabelKey` label set on all the applied objects.
+	Name string
+	// AllowedGVKs the kinds of objects which can be pruned. The objects of other kinds are never pruned.
+	// Note: the version of the objects is ignored, ie, only the group and the kind are compared.
+	AllowedGVKs []schema.GroupVersionKind
+}
+
+// inventoryEntry an object recorded in the inventory
+type inventoryEntry struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+func (e inventoryEntry) gvk() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: e.Group, Version: e.Version, Kind: e.Kind}
+}
+
+// key returns a key which identifies the object, regardless of its version
+func (e inventoryEntry) key() string {
+	return e.Group + "/" + e.Kind + "/" + e.Namespace + "/" + e.Name
+}
+
+// ApplyWithPrune applies the objects (see `Apply`) and records them in the given inventory. Then, it deletes all the objects which were previously applied
+// with the same inventory but which are not part of the given objects anymore, unless:
+// - their kind is not in the allowlist of the inventory,
+// - they are annotated with `toolchain.dev.openshift.com/prune-protected: true`,
+// - or they are not labelled with the inventory key anymore.
+// No object is pruned if any of the given objects could not be applied, but all the given objects are still recorded in the inventory.
+// Returns the result of each given object, at the same index (see `Apply`), along with the objects which were pruned,
+// and an (aggregated) error if some objects could not be applied or pruned
+func (c ApplyClient) ApplyWithPrune(toolchainObjects []client.Object, newLabels map[string]string, inventory Inventory) (ApplyResults, []client.Object, error) {
+	return c.ApplyWithPruneCtx(context.TODO(), toolchainObjects, newLabels, inventory)
+}
+
+// ApplyWithPruneCtx is the same as ApplyWithPrune, but all the requests to the server are bound to the given context
+func (c ApplyClient) ApplyWithPruneCtx(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string, inventory Inventory) (ApplyResults, []client.Object, error) {
+	previousEntries, err := c.getInventoryEntries(ctx, inventory)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	desiredEntries := make([]inventoryEntry, len(toolchainObjects))
+	desiredKeys := make(map[string]bool, len(toolchainObjects))
+	for i, obj := range toolchainObjects {
+		gvk, err := apiutil.GVKForObject(obj, c.Client.Scheme())
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to get the GroupVersionKind of the resource '%v'", obj)
+		}
+		desiredEntries[i] = inventoryEntry{
+			Group:     gvk.Group,
+			Version:   gvk.Version,
+			Kind:      gvk.Kind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		}
+		desiredKeys[desiredEntries[i].key()] = true
+	}
+
+	labels := map[string]string{}
+	for k, v := range newLabels {
+		labels[k] = v
+	}
+	labels[InventoryLabelKey] = inventory.Name
+	results, err := c.ApplyCtx(ctx, toolchainObjects, labels)
+	if err != nil {
+		// the objects which were applied are recorded (along with the previous ones, which are not pruned),
+		// so that they can be pruned later on
+		entries := desiredEntries
+		for _, entry := range previousEntries {
+			if !desiredKeys[entry.key()] {
+				entries = append(entries, entry)
+			}
+		}
+		if saveErr := c.saveInventoryEntries(ctx, inventory, entries); saveErr != nil {
+			return results, nil, utilerrors.NewAggregate([]error{err, saveErr})
+		}
+		return results, nil, err
+	}
+
+	// the objects which could not be deleted are kept in the inventory, so that there's another attempt next time
+	entries := desiredEntries
+	var pruned []client.Object
+	var errs []error
+	for _, entry := range previousEntries {
+		if desiredKeys[entry.key()] {
+			continue
+		}
+		obj, err := c.pruneObject(ctx, inventory, entry)
+		if err != nil {
+			errs = append(errs, err)
+			entries = append(entries, entry)
+			continue
+		}
+		if obj != nil {
+			pruned = append(pruned, obj)
+		}
+	}
+
+	if err := c.saveInventoryEntries(ctx, inventory, entries); err != nil {
+		errs = append(errs, err)
+	}
+	return results, pruned, utilerrors.NewAggregate(errs)
+}
+
+// pruneObject deletes the object corresponding to the given entry, if allowed.
+// Returns the deleted object, or `nil` if the object was not deleted
+func (c ApplyClient) pruneObject(ctx context.Context, inventory Inventory, entry inventoryEntry) (client.Object, error) {
+	if !isPruneAllowed(inventory, entry.gvk()) {
+		log.Info("not pruning object since its kind is not allowed", "inventory", inventory.Name, "gvk", entry.gvk(), "namespace", entry.Namespace, "name", entry.Name)
+		return nil, nil
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(entry.gvk())
+	if err := c.Client.Get(ctx, types.NamespacedName{Namespace: entry.Namespace, Name: entry.Name}, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "unable to get the resource of kind '%s' with name '%s' to prune", entry.Kind, entry.Name)
+	}
+	if obj.GetAnnotations()[PruneProtectionAnnotationKey] == "true" {
+		log.Info("not pruning object since it is protected", "inventory", inventory.Name, "gvk", entry.gvk(), "namespace", entry.Namespace, "name", entry.Name)
+		return nil, nil
+	}
+	if obj.GetLabels()[InventoryLabelKey] != inventory.Name {
+		log.Info("not pruning object since it does not belong to the inventory anymore", "inventory", inventory.Name, "gvk", entry.gvk(), "namespace", entry.Namespace, "name", entry.Name)
+		return nil, nil
+	}
+	log.Info("pruning object", "inventory", inventory.Name, "gvk", entry.gvk(), "namespace", entry.Namespace, "name", entry.Name)
+	if err := c.Client.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "unable to prune the resource of kind '%s' with name '%s'", entry.Kind, entry.Name)
+	}
+	return obj, nil
+}
+
+func isPruneAllowed(inventory Inventory, gvk schema.GroupVersionKind) bool {
+	for _, allowed := range inventory.AllowedGVKs {
+		if allowed.GroupKind() == gvk.GroupKind() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c ApplyClient) getInventoryEntries(ctx context.Context, inventory Inventory) ([]inventoryEntry, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Client.Get(ctx, types.NamespacedName{Namespace: inventory.Namespace, Name: inventory.Name}, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "unable to get the inventory '%s'", inventory.Name)
+	}
+	var entries []inventoryEntry
+	if content, found := cm.Data[inventoryDataKey]; found {
+		if err := json.Unmarshal([]byte(content), &entries); err != nil {
+			return nil, errors.Wrapf(err, "unable to read the content of the inventory '%s'", inventory.Name)
+		}
+	}
+	return entries, nil
+}
+
+func (c ApplyClient) saveInventoryEntries(ctx context.Context, inventory Inventory, entries []inventoryEntry) error {
+	// sort the entries to avoid unnecessary updates of the inventory
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key() < entries[j].key()
+	})
+	content, err := json.Marshal(entries)
+	if err != nil {
+		return errors.Wrapf(err, "unable to write the content of the inventory '%s'", inventory.Name)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: inventory.Namespace,
+			Name:      inventory.Name,
+			Labels: map[string]string{
+				InventoryLabelKey: inventory.Name,
+			},
+		},
+		Data: map[string]string{
+			inventoryDataKey: string(content),
+		},
+	}
+	if _, err := c.ApplyObjectCtx(ctx, cm, ForceUpdate(true), SaveConfiguration(false)); err != nil {
+		return errors.Wrapf(err, "unable to save the inventory '%s'", inventory.Name)
+	}
+	return nil
+}
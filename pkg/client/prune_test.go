@@ -0,0 +1,285 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyWithPrune(t *testing.T) {
+	// given
+	addToScheme(t)
+	labels := newLabels("base1ns", "john", "dev")
+	inventory := client.Inventory{
+		Namespace: "toolchain-member-operator",
+		Name:      "john-dev-inventory",
+		AllowedGVKs: []schema.GroupVersionKind{
+			corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+		},
+	}
+
+	data := map[string]string{"param": "value"}
+	// the objects which were already applied with the inventory
+	applied := []runtimeclient.Object{newConfigMap("john-dev", "cm-1", data), newConfigMap("john-dev", "cm-2", data), newSA()}
+	assertExists := func(t *testing.T, cl runtimeclient.Client, obj runtimeclient.Object) {
+		err := cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), obj)
+		require.NoError(t, err)
+		assert.Equal(t, inventory.Name, obj.GetLabels()[client.InventoryLabelKey])
+	}
+	assertNotExists := func(t *testing.T, cl runtimeclient.Client, obj runtimeclient.Object) {
+		err := cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), obj)
+		require.Error(t, err)
+		assert.True(t, apierrors.IsNotFound(err))
+	}
+
+	t.Run("should record the applied objects in the inventory", func(t *testing.T) {
+		// when
+		_, cli := newClientWithInventory(t, labels, inventory, applied...)
+
+		// then
+		assertExists(t, cli, newConfigMap("john-dev", "cm-1", data))
+		assertExists(t, cli, newConfigMap("john-dev", "cm-2", data))
+		assertExists(t, cli, newSA())
+		inventoryCM := &corev1.ConfigMap{}
+		err := cli.Get(context.TODO(), types.NamespacedName{Namespace: inventory.Namespace, Name: inventory.Name}, inventoryCM)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"version":"v1","kind":"ConfigMap","namespace":"john-dev","name":"cm-1"},
+			{"version":"v1","kind":"ConfigMap","namespace":"john-dev","name":"cm-2"},
+			{"version":"v1","kind":"ServiceAccount","namespace":"john-dev","name":"appstudio-user-sa"}
+		]`, inventoryCM.Data["inventory"])
+	})
+
+	t.Run("should not change anything when applying the same objects", func(t *testing.T) {
+		// given
+		cl, cli := newClientWithInventory(t, labels, inventory, applied...)
+
+		// when
+		results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newConfigMap("john-dev", "cm-1", data), newConfigMap("john-dev", "cm-2", data), newSA()}, labels, inventory)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, results.CreatedOrUpdated())
+		assert.Empty(t, pruned)
+		assertExists(t, cli, newConfigMap("john-dev", "cm-1", data))
+		assertExists(t, cli, newConfigMap("john-dev", "cm-2", data))
+		assertExists(t, cli, newSA())
+	})
+
+	t.Run("should prune objects which are not part of the desired objects anymore", func(t *testing.T) {
+		// given
+		cl, cli := newClientWithInventory(t, labels, inventory, applied...)
+
+		// when
+		results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newConfigMap("john-dev", "cm-1", data), newSA()}, labels, inventory)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, results.CreatedOrUpdated())
+		assert.Equal(t, []string{"cm-2"}, names(pruned))
+		assertExists(t, cli, newConfigMap("john-dev", "cm-1", data))
+		assertNotExists(t, cli, newConfigMap("john-dev", "cm-2", data))
+		assertExists(t, cli, newSA())
+
+		t.Run("pruned object is removed from the inventory", func(t *testing.T) {
+			// when
+			results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newConfigMap("john-dev", "cm-1", data), newSA()}, labels, inventory)
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, results.CreatedOrUpdated())
+			assert.Empty(t, pruned)
+			inventoryCM := &corev1.ConfigMap{}
+			err = cli.Get(context.TODO(), types.NamespacedName{Namespace: inventory.Namespace, Name: inventory.Name}, inventoryCM)
+			require.NoError(t, err)
+			assert.NotContains(t, inventoryCM.Data["inventory"], "cm-2")
+		})
+	})
+
+	t.Run("should not prune objects whose kind is not allowed", func(t *testing.T) {
+		// given
+		cl, cli := newClientWithInventory(t, labels, inventory, applied...)
+
+		// when
+		results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newConfigMap("john-dev", "cm-1", data), newConfigMap("john-dev", "cm-2", data)}, labels, inventory)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, results.CreatedOrUpdated())
+		assert.Empty(t, pruned)
+		assertExists(t, cli, newSA())
+	})
+
+	t.Run("should not prune objects which are protected", func(t *testing.T) {
+		// given
+		cl, cli := newClientWithInventory(t, labels, inventory, applied...)
+		cm := newConfigMap("john-dev", "cm-2", data)
+		require.NoError(t, cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), cm))
+		cm.Annotations[client.PruneProtectionAnnotationKey] = "true"
+		require.NoError(t, cli.Update(context.TODO(), cm))
+
+		// when
+		results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newConfigMap("john-dev", "cm-1", data), newSA()}, labels, inventory)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, results.CreatedOrUpdated())
+		assert.Empty(t, pruned)
+		assertExists(t, cli, newConfigMap("john-dev", "cm-2", data))
+	})
+
+	t.Run("should not prune objects which belong to another inventory", func(t *testing.T) {
+		// given
+		cl, cli := newClientWithInventory(t, labels, inventory, applied...)
+		cm := newConfigMap("john-dev", "cm-2", data)
+		require.NoError(t, cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), cm))
+		cm.Labels[client.InventoryLabelKey] = "other-inventory"
+		require.NoError(t, cli.Update(context.TODO(), cm))
+
+		// when
+		results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newConfigMap("john-dev", "cm-1", data), newSA()}, labels, inventory)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, results.CreatedOrUpdated())
+		assert.Empty(t, pruned)
+		err = cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), cm)
+		require.NoError(t, err)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("should not prune when objects cannot be applied", func(t *testing.T) {
+			// given
+			cl, cli := newClientWithInventory(t, labels, inventory, applied...)
+			cli.MockCreate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+				return errors.New("mock error")
+			}
+
+			// when
+			results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newConfigMap("john-dev", "cm-3", data)}, labels, inventory)
+
+			// then
+			require.Error(t, err)
+			assert.Len(t, results.Failed(), 1)
+			assert.Empty(t, pruned)
+			assertExists(t, cli, newConfigMap("john-dev", "cm-1", data))
+			assertExists(t, cli, newConfigMap("john-dev", "cm-2", data))
+		})
+
+		t.Run("should record the applied objects when other objects cannot be applied", func(t *testing.T) {
+			// given
+			cl, cli := newClientWithInventory(t, labels, inventory, applied...)
+			cli.MockCreate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+				if obj.GetName() == "cm-4" {
+					return errors.New("mock error")
+				}
+				return cli.Client.Create(ctx, obj, opts...)
+			}
+
+			// when
+			results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newConfigMap("john-dev", "cm-3", data), newConfigMap("john-dev", "cm-4", data)}, labels, inventory)
+
+			// then
+			require.Error(t, err)
+			assert.Equal(t, client.ApplyCreated, results[0].Operation)
+			assert.Equal(t, client.ApplyFailed, results[1].Operation)
+			assert.Empty(t, pruned)
+			assertExists(t, cli, newConfigMap("john-dev", "cm-1", data))
+			assertExists(t, cli, newConfigMap("john-dev", "cm-2", data))
+			assertExists(t, cli, newConfigMap("john-dev", "cm-3", data))
+			inventoryCM := &corev1.ConfigMap{}
+			err = cli.Get(context.TODO(), types.NamespacedName{Namespace: inventory.Namespace, Name: inventory.Name}, inventoryCM)
+			require.NoError(t, err)
+			for _, name := range []string{"cm-1", "cm-2", "cm-3", "cm-4", "appstudio-user-sa"} {
+				assert.Contains(t, inventoryCM.Data["inventory"], `"name":"`+name+`"`)
+			}
+
+			t.Run("and prune them once not desired anymore", func(t *testing.T) {
+				// given
+				cli.MockCreate = nil
+
+				// when
+				results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newSA()}, labels, inventory)
+
+				// then
+				require.NoError(t, err)
+				assert.False(t, results.CreatedOrUpdated())
+				assert.ElementsMatch(t, []string{"cm-1", "cm-2", "cm-3"}, names(pruned))
+				assertNotExists(t, cli, newConfigMap("john-dev", "cm-1", data))
+				assertNotExists(t, cli, newConfigMap("john-dev", "cm-2", data))
+				assertNotExists(t, cli, newConfigMap("john-dev", "cm-3", data))
+				assertExists(t, cli, newSA())
+			})
+		})
+
+		t.Run("should keep the object in the inventory when it cannot be deleted", func(t *testing.T) {
+			// given
+			cl, cli := newClientWithInventory(t, labels, inventory, applied...)
+			cli.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+				return errors.New("mock error")
+			}
+
+			// when
+			results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newConfigMap("john-dev", "cm-1", data), newSA()}, labels, inventory)
+
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unable to prune the resource of kind 'ConfigMap' with name 'cm-2': mock error")
+			assert.False(t, results.CreatedOrUpdated())
+			assert.Empty(t, pruned)
+			inventoryCM := &corev1.ConfigMap{}
+			err = cli.Get(context.TODO(), types.NamespacedName{Namespace: inventory.Namespace, Name: inventory.Name}, inventoryCM)
+			require.NoError(t, err)
+			assert.Contains(t, inventoryCM.Data["inventory"], "cm-2")
+
+			t.Run("and prune it next time", func(t *testing.T) {
+				// given
+				cli.MockDelete = nil
+
+				// when
+				results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newConfigMap("john-dev", "cm-1", data), newSA()}, labels, inventory)
+
+				// then
+				require.NoError(t, err)
+				assert.False(t, results.CreatedOrUpdated())
+				assert.Equal(t, []string{"cm-2"}, names(pruned))
+				assertNotExists(t, cli, newConfigMap("john-dev", "cm-2", data))
+			})
+		})
+
+		t.Run("should fail when inventory cannot be read", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			cli.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+				return errors.New("mock error")
+			}
+
+			// when
+			results, pruned, err := cl.ApplyWithPrune([]runtimeclient.Object{newConfigMap("john-dev", "cm-1", data)}, labels, inventory)
+
+			// then
+			require.EqualError(t, err, "unable to get the inventory 'john-dev-inventory': mock error")
+			assert.Empty(t, results)
+			assert.Empty(t, pruned)
+		})
+	})
+}
+
+// names returns the names of the given objects, in the same order
+func names(objs []runtimeclient.Object) []string {
+	names := make([]string, len(objs))
+	for i, obj := range objs {
+		names[i] = obj.GetName()
+	}
+	return names
+}
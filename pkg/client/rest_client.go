@@ -13,24 +13,32 @@ import (
 // CreateTokenRequest creates a TokenRequest for a service account using given expiration in seconds.
 // Returns the token string and nil if everything went fine, otherwise an empty string and an error is returned in case something went wrong.
 func CreateTokenRequest(restClient *rest.RESTClient, namespacedName types.NamespacedName, expirationInSeconds int) (string, error) {
+	status, err := createTokenRequest(context.TODO(), restClient, namespacedName, authv1.TokenRequestSpec{
+		ExpirationSeconds: pointer.Int64(int64(expirationInSeconds)),
+	})
+	if err != nil {
+		return "", err
+	}
+	// return the token string
+	return status.Token, nil
+}
+
+// createTokenRequest creates a TokenRequest with the given spec for a service account, and returns its status
+func createTokenRequest(ctx context.Context, restClient *rest.RESTClient, namespacedName types.NamespacedName, spec authv1.TokenRequestSpec) (authv1.TokenRequestStatus, error) {
 	tokenRequest := &authv1.TokenRequest{
-		Spec: authv1.TokenRequestSpec{
-			ExpirationSeconds: pointer.Int64(int64(expirationInSeconds)),
-		},
+		Spec: spec,
 	}
 	result := &authv1.TokenRequest{}
 	if err := restClient.Post().
 		AbsPath(fmt.Sprintf("api/v1/namespaces/%s/serviceaccounts/%s/token", namespacedName.Namespace, namespacedName.Name)).
 		Body(tokenRequest).
-		Do(context.TODO()).
+		Do(ctx).
 		Into(result); err != nil {
-		return "", err
+		return authv1.TokenRequestStatus{}, err
 	}
 
 	if len(result.Status.Token) == 0 {
-		return "", fmt.Errorf("unable to create token, got empty string")
+		return authv1.TokenRequestStatus{}, fmt.Errorf("unable to create token, got empty string")
 	}
-
-	// return the token string
-	return result.Status.Token, nil
+	return result.Status, nil
 }
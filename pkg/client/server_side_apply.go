@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// DefaultFieldManager the name of the field manager used by the server-side apply when none is specified
+const DefaultFieldManager = "codeready-toolchain"
+
+// serverSideApply creates or updates the given object using the server-side apply.
+// The returned operation says if the object was created, updated (ie, its generation was incremented by the server), updated on its metadata only, or unchanged.
+func (c ApplyClient) serverSideApply(ctx context.Context, obj client.Object, config applyObjectConfiguration) (ApplyOperation, error) {
+	// the server-side apply requires the `apiVersion` and `kind` to be set in the request body,
+	// which is not always the case with typed objects
+	gvk, err := apiutil.GVKForObject(obj, c.Client.Scheme())
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get the GroupVersionKind of the resource '%v'", obj)
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	// gets current object (if exists) to know if the resource will be created or updated
+	existing := obj.DeepCopyObject().(client.Object)
+	exists := true
+	namespacedName := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	if err := c.Client.Get(ctx, namespacedName, existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", errors.Wrapf(err, "unable to get the resource '%v'", existing)
+		}
+		exists = false
+	}
+
+	if config.owner != nil {
+		if err := controllerutil.SetControllerReference(config.owner, obj, c.Client.Scheme()); err != nil {
+			return "", errors.Wrap(err, "unable to set controller references")
+		}
+	}
+	// the managed fields must not be part of an apply request, and the resource version
+	// is reset to make sure that the request is not rejected because of a stale version
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+
+	opts := []client.PatchOption{client.FieldOwner(config.fieldManager)}
+	if config.forceConflicts {
+		opts = append(opts, client.ForceOwnership)
+	}
+	if config.dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	if err := c.Client.Patch(ctx, obj, client.Apply, opts...); err != nil {
+		if conflicts := fieldConflicts(err); len(conflicts) > 0 {
+			return "", &ApplyConflictError{
+				GVK:            gvk,
+				NamespacedName: namespacedName,
+				FieldManager:   config.fieldManager,
+				Conflicts:      conflicts,
+				err:            err,
+			}
+		}
+		return "", errors.Wrapf(err, "unable to apply the resource '%v'", obj)
+	}
+	if !exists {
+		return ApplyCreated, nil
+	}
+	return updateOperation(existing, obj), nil
+}
+
+// FieldConflict a conflict on a single field of a resource, which is owned by another field manager
+type FieldConflict struct {
+	// Field the path of the field in conflict, eg: `.spec.replicas`
+	Field string
+	// Manager the name of the field manager which owns the field (if it could be determined)
+	Manager string
+	// Message the message returned by the server for this conflict
+	Message string
+}
+
+// ApplyConflictError the error returned when a server-side apply was rejected by the server
+// because some fields are owned by other field managers
+type ApplyConflictError struct {
+	GVK            schema.GroupVersionKind
+	NamespacedName types.NamespacedName
+	// FieldManager the name of the field manager used to apply the resource
+	FieldManager string
+	// Conflicts the fields in conflict
+	Conflicts []FieldConflict
+	err       error
+}
+
+var _ error = &ApplyConflictError{}
+
+func (e *ApplyConflictError) Error() string {
+	fields := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		if c.Manager != "" {
+			fields[i] = fmt.Sprintf("%s (owned by '%s')", c.Field, c.Manager)
+		} else {
+			fields[i] = c.Field
+		}
+	}
+	return fmt.Sprintf("unable to apply the resource of kind '%s' with name '%s' using field manager '%s' because of conflicts on the following fields: %s",
+		e.GVK.Kind, e.NamespacedName, e.FieldManager, strings.Join(fields, ", "))
+}
+
+// Unwrap returns the original error returned by the server
+func (e *ApplyConflictError) Unwrap() error {
+	return e.err
+}
+
+// IsApplyConflict returns `true` if the given error (or any error it wraps) is an ApplyConflictError
+func IsApplyConflict(err error) bool {
+	var conflictErr *ApplyConflictError
+	return errors.As(err, &conflictErr)
+}
+
+// conflictManagerRegexp extracts the name of the manager from a conflict message such as
+// `conflict with "kube-controller-manager" using apps/v1: .spec.replicas`
+var conflictManagerRegexp = regexp.MustCompile(`conflict with "([^"]*)"`)
+
+// fieldConflicts returns the field conflicts contained in the given error (if any)
+func fieldConflicts(err error) []FieldConflict {
+	if !apierrors.IsConflict(err) {
+		return nil
+	}
+	var statusErr apierrors.APIStatus
+	if !errors.As(err, &statusErr) || statusErr.Status().Details == nil {
+		return nil
+	}
+	var conflicts []FieldConflict
+	for _, cause := range statusErr.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		conflict := FieldConflict{
+			Field:   cause.Field,
+			Message: cause.Message,
+		}
+		if matches := conflictManagerRegexp.FindStringSubmatch(cause.Message); len(matches) == 2 {
+			conflict.Manager = matches[1]
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
@@ -0,0 +1,153 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyObjectWithServerSideApply(t *testing.T) {
+	// given
+	addToScheme(t)
+
+	namespacedName := types.NamespacedName{Namespace: "toolchain-host-operator", Name: "registration-service"}
+
+	t.Run("when object is missing, it should create it", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		patchOptions := mockServerSideApply(t, cli)
+
+		// when
+		result, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}), client.ServerSideApply("host-operator"), client.SetOwner(&appsv1.Deployment{}))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, result.CreatedOrUpdated())
+		assert.Equal(t, "host-operator", patchOptions.FieldManager)
+		assert.Nil(t, patchOptions.Force)
+		configMap := &corev1.ConfigMap{}
+		err = cli.Get(context.TODO(), namespacedName, configMap)
+		require.NoError(t, err)
+		assert.Equal(t, "first-value", configMap.Data["first-param"])
+		assert.NotEmpty(t, configMap.OwnerReferences)
+		assert.Empty(t, configMap.Annotations[client.LastAppliedConfigurationAnnotationKey])
+	})
+
+	t.Run("when object exists, it should update it", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t, newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}))
+		patchOptions := mockServerSideApply(t, cli)
+		modified := newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"})
+		modified.Data["first-param"] = "second-value"
+
+		// when
+		result, err := cl.ApplyObject(modified, client.ServerSideApply(""), client.ForceConflicts(true))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, result.CreatedOrUpdated())
+		assert.Equal(t, client.DefaultFieldManager, patchOptions.FieldManager)
+		require.NotNil(t, patchOptions.Force)
+		assert.True(t, *patchOptions.Force)
+		configMap := &corev1.ConfigMap{}
+		err = cli.Get(context.TODO(), namespacedName, configMap)
+		require.NoError(t, err)
+		assert.Equal(t, "second-value", configMap.Data["first-param"])
+	})
+
+	t.Run("when object did not change, it should return false", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t, newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}))
+		mockServerSideApply(t, cli)
+
+		// when
+		result, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}), client.ServerSideApply(""))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, result.CreatedOrUpdated())
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("when there are conflicts, it should return a structured error", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t, newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}))
+			cli.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+				return apierrors.NewApplyConflict([]metav1.StatusCause{
+					{
+						Type:    metav1.CauseTypeFieldManagerConflict,
+						Message: `conflict with "other-operator" using v1: .data.first-param`,
+						Field:   ".data.first-param",
+					},
+				}, `Apply failed with 1 conflict: conflict with "other-operator" using v1: .data.first-param`)
+			}
+
+			// when
+			result, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}), client.ServerSideApply("host-operator"))
+
+			// then
+			require.Error(t, err)
+			assert.False(t, result.CreatedOrUpdated())
+			assert.True(t, client.IsApplyConflict(err))
+			assert.True(t, apierrors.IsConflict(err))
+			conflictErr := &client.ApplyConflictError{}
+			require.True(t, errors.As(err, &conflictErr))
+			assert.Equal(t, "host-operator", conflictErr.FieldManager)
+			assert.Equal(t, namespacedName, conflictErr.NamespacedName)
+			assert.Equal(t, []client.FieldConflict{
+				{
+					Field:   ".data.first-param",
+					Manager: "other-operator",
+					Message: `conflict with "other-operator" using v1: .data.first-param`,
+				},
+			}, conflictErr.Conflicts)
+			assert.Contains(t, err.Error(), ".data.first-param (owned by 'other-operator')")
+		})
+
+		t.Run("when patch fails for any other reason, it should return the error", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			cli.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+				return errors.New("mock error")
+			}
+
+			// when
+			result, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}), client.ServerSideApply(""))
+
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unable to apply the resource")
+			assert.Contains(t, err.Error(), "mock error")
+			assert.False(t, result.CreatedOrUpdated())
+			assert.False(t, client.IsApplyConflict(err))
+		})
+
+		t.Run("when object cannot be retrieved, it should return the error", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			mockServerSideApply(t, cli)
+			cli.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+				return errors.New("mock error")
+			}
+
+			// when
+			result, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}), client.ServerSideApply(""))
+
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unable to get the resource")
+			assert.False(t, result.CreatedOrUpdated())
+		})
+	})
+}
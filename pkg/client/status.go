@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ApplyStatus updates the status of the existing object with the status of the given object (typed or unstructured).
+// The update is skipped when the status of the existing object is already the same. In case of conflict, the existing object
+// is read again and the update is retried (see `ConflictRetryBackoff`), since only the status is replaced.
+// The returned result contains the existing object (with its updated status) and says if the status was updated or unchanged.
+// The resource version of the given object is set to the one of the updated object.
+func (c ApplyClient) ApplyStatus(obj client.Object) (ApplyResult, error) {
+	return c.ApplyStatusCtx(context.TODO(), obj)
+}
+
+// ApplyStatusCtx is the same as ApplyStatus, but all the requests to the server are bound to the given context
+func (c ApplyClient) ApplyStatusCtx(ctx context.Context, obj client.Object) (ApplyResult, error) {
+	gvk := c.gvkForObject(obj)
+	result := ApplyResult{
+		GVK: gvk,
+	}
+	content, err := unstructuredContent(obj)
+	if err != nil {
+		return result, errors.Wrapf(err, "unable to convert the resource of kind '%s'", gvk.Kind)
+	}
+	desired, _, err := unstructured.NestedFieldCopy(content, "status")
+	if err != nil {
+		return result, errors.Wrapf(err, "unable to read the status of the resource of kind '%s'", gvk.Kind)
+	}
+
+	if waitErr := wait.ExponentialBackoffWithContext(ctx, c.conflictBackoff(), func() (bool, error) {
+		result.Operation, result.Object, err = c.applyStatus(ctx, obj, desired)
+		if err == nil || !apierrors.IsConflict(err) {
+			return true, nil
+		}
+		log.Info("conflict while updating the status of the object, retrying", "kind", gvk.Kind, "namespace", obj.GetNamespace(), "name", obj.GetName())
+		return false, nil
+	}); waitErr != nil && err == nil {
+		// the context was done before the first attempt
+		err = waitErr
+	}
+	if err != nil {
+		return result, errors.Wrapf(err, "unable to update the status of the resource of kind: %s, version: %s, namespace: '%s', name: '%s'",
+			gvk.Kind, gvk.Version, obj.GetNamespace(), obj.GetName())
+	}
+	obj.SetResourceVersion(result.Object.GetResourceVersion())
+	return result, nil
+}
+
+// applyStatus reads the existing object and replaces its status with the desired one, unless they are already the same
+func (c ApplyClient) applyStatus(ctx context.Context, obj client.Object, desired interface{}) (ApplyOperation, client.Object, error) {
+	existing := obj.DeepCopyObject().(client.Object)
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		return "", nil, err
+	}
+	content, err := unstructuredContent(existing)
+	if err != nil {
+		return "", nil, err
+	}
+	live, _, err := unstructured.NestedFieldNoCopy(content, "status")
+	if err != nil {
+		return "", nil, err
+	}
+	// a `null` field and a missing field are the same
+	if equality.Semantic.DeepEqual(withoutNullValues(desired), withoutNullValues(live)) {
+		return ApplyUnchanged, existing, nil
+	}
+	if desired == nil {
+		unstructured.RemoveNestedField(content, "status")
+	} else if err := unstructured.SetNestedField(content, runtime.DeepCopyJSONValue(desired), "status"); err != nil {
+		return "", nil, err
+	}
+	if _, ok := existing.(runtime.Unstructured); !ok {
+		// the conversion into a fresh object makes sure that the fields which are not part of the desired status are cleared
+		updated := reflect.New(reflect.TypeOf(existing).Elem()).Interface().(client.Object)
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, updated); err != nil {
+			return "", nil, err
+		}
+		existing = updated
+	}
+	if err := c.Client.Status().Update(ctx, existing); err != nil {
+		return "", nil, err
+	}
+	return ApplyUpdated, existing, nil
+}
+
+// withoutNullValues returns a copy of the given value, without the entries of the maps whose value is `nil`
+func withoutNullValues(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			if v != nil {
+				result[k] = withoutNullValues(v)
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, v := range value {
+			result[i] = withoutNullValues(v)
+		}
+		return result
+	default:
+		return value
+	}
+}
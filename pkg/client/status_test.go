@@ -0,0 +1,206 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	. "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyStatus(t *testing.T) {
+	// given
+	addToScheme(t)
+	namespacedName := types.NamespacedName{Namespace: "toolchain-host-operator", Name: "john"}
+	newUserSignup := func(username string, conditions ...toolchainv1alpha1.Condition) *toolchainv1alpha1.UserSignup {
+		return &toolchainv1alpha1.UserSignup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      namespacedName.Name,
+				Namespace: namespacedName.Namespace,
+			},
+			Spec: toolchainv1alpha1.UserSignupSpec{
+				Username: "john@redhat.com",
+			},
+			Status: toolchainv1alpha1.UserSignupStatus{
+				CompliantUsername: username,
+				Conditions:        conditions,
+			},
+		}
+	}
+	approved := toolchainv1alpha1.Condition{
+		Type:   toolchainv1alpha1.UserSignupApproved,
+		Status: corev1.ConditionTrue,
+		Reason: "ApprovedAutomatically",
+	}
+	newClientWithUserSignup := func(t *testing.T) (*client.ApplyClient, *FakeClient) {
+		fakeClient := NewFakeClient(t, newUserSignup("john", approved))
+		return client.NewApplyClient(fakeClient), fakeClient
+	}
+
+	t.Run("typed object", func(t *testing.T) {
+
+		t.Run("should update status", func(t *testing.T) {
+			// given
+			cl, cli := newClientWithUserSignup(t)
+			// the spec was changed in the meantime
+			existing := &toolchainv1alpha1.UserSignup{}
+			require.NoError(t, cli.Get(context.TODO(), namespacedName, existing))
+			existing.Spec.Username = "john.doe@redhat.com"
+			require.NoError(t, cli.Update(context.TODO(), existing))
+			desired := newUserSignup("john-doe")
+
+			// when
+			result, err := cl.ApplyStatus(desired)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyUpdated, result.Operation)
+			assert.Equal(t, "UserSignup", result.GVK.Kind)
+			userSignup := &toolchainv1alpha1.UserSignup{}
+			require.NoError(t, cli.Get(context.TODO(), namespacedName, userSignup))
+			assert.Equal(t, "john-doe", userSignup.Status.CompliantUsername)
+			// the conditions which are not part of the desired status are removed
+			assert.Empty(t, userSignup.Status.Conditions)
+			// the spec is not modified
+			assert.Equal(t, "john.doe@redhat.com", userSignup.Spec.Username)
+			assert.Equal(t, userSignup.ResourceVersion, desired.ResourceVersion)
+		})
+
+		t.Run("should skip update when status did not change", func(t *testing.T) {
+			// given
+			cl, cli := newClientWithUserSignup(t)
+			cli.MockStatusUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				t.Fatal("should not update the status")
+				return nil
+			}
+
+			// when
+			result, err := cl.ApplyStatus(newUserSignup("john", approved))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyUnchanged, result.Operation)
+			assert.False(t, result.CreatedOrUpdated())
+		})
+
+		t.Run("should retry on conflict", func(t *testing.T) {
+			// given
+			cl, cli := newClientWithUserSignup(t)
+			updates := 0
+			cli.MockStatusUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				updates++
+				if updates == 1 {
+					return apierrors.NewConflict(schema.GroupResource{Resource: "usersignups"}, obj.GetName(), fmt.Errorf("the object has been modified"))
+				}
+				return cli.Client.Status().Update(ctx, obj, opts...)
+			}
+
+			// when
+			result, err := cl.ApplyStatus(newUserSignup("john-doe", approved))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyUpdated, result.Operation)
+			assert.Equal(t, 2, updates)
+			userSignup := &toolchainv1alpha1.UserSignup{}
+			require.NoError(t, cli.Get(context.TODO(), namespacedName, userSignup))
+			assert.Equal(t, "john-doe", userSignup.Status.CompliantUsername)
+		})
+
+		t.Run("should fail when conflicts persist", func(t *testing.T) {
+			// given
+			cl := client.NewApplyClient(NewFakeClient(t, newUserSignup("john")), client.ConflictRetryBackoff(wait.Backoff{Steps: 3}))
+			updates := 0
+			cl.Client.(*FakeClient).MockStatusUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				updates++
+				return apierrors.NewConflict(schema.GroupResource{Resource: "usersignups"}, obj.GetName(), fmt.Errorf("the object has been modified"))
+			}
+
+			// when
+			_, err := cl.ApplyStatus(newUserSignup("john-doe"))
+
+			// then
+			require.Error(t, err)
+			assert.True(t, apierrors.IsConflict(err))
+			assert.Equal(t, 3, updates)
+		})
+
+		t.Run("should fail when object does not exist", func(t *testing.T) {
+			// given
+			cl, _ := newClient(t)
+
+			// when
+			_, err := cl.ApplyStatus(newUserSignup("john"))
+
+			// then
+			require.Error(t, err)
+			assert.True(t, apierrors.IsNotFound(err))
+			assert.Contains(t, err.Error(), "unable to update the status of the resource of kind: UserSignup, version: v1alpha1, namespace: 'toolchain-host-operator', name: 'john'")
+		})
+	})
+
+	t.Run("unstructured object", func(t *testing.T) {
+
+		newUnstructuredUserSignup := func(username string) *unstructured.Unstructured {
+			obj := &unstructured.Unstructured{}
+			obj.SetAPIVersion("toolchain.dev.openshift.com/v1alpha1")
+			obj.SetKind("UserSignup")
+			obj.SetNamespace(namespacedName.Namespace)
+			obj.SetName(namespacedName.Name)
+			require.NoError(t, unstructured.SetNestedField(obj.Object, username, "status", "compliantUsername"))
+			return obj
+		}
+
+		t.Run("should update status", func(t *testing.T) {
+			// given
+			cl, cli := newClientWithUserSignup(t)
+
+			// when
+			result, err := cl.ApplyStatus(newUnstructuredUserSignup("john-doe"))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyUpdated, result.Operation)
+			userSignup := &toolchainv1alpha1.UserSignup{}
+			require.NoError(t, cli.Get(context.TODO(), namespacedName, userSignup))
+			assert.Equal(t, "john-doe", userSignup.Status.CompliantUsername)
+			assert.Empty(t, userSignup.Status.Conditions)
+		})
+
+		t.Run("should skip update when status did not change", func(t *testing.T) {
+			// given
+			cl, cli := newClientWithUserSignup(t)
+			cli.MockStatusUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				t.Fatal("should not update the status")
+				return nil
+			}
+			desired := newUnstructuredUserSignup("john")
+			require.NoError(t, unstructured.SetNestedSlice(desired.Object, []interface{}{
+				map[string]interface{}{
+					"type":   string(toolchainv1alpha1.UserSignupApproved),
+					"status": string(corev1.ConditionTrue),
+					"reason": "ApprovedAutomatically",
+				},
+			}, "status", "conditions"))
+
+			// when
+			result, err := cl.ApplyStatus(desired)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyUnchanged, result.Operation)
+		})
+	})
+}
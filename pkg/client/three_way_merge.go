@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/mergepatch"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// builtInScheme contains only the Kubernetes built-in types, ie, the types for which the API server supports
+// the strategic merge patches. All other types (CRDs, aggregated APIs) are patched using JSON merge patches.
+var builtInScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(builtInScheme))
+}
+
+// patchObject computes a three-way merge patch between the last applied configuration (stored in the annotation of the existing object),
+// the new object and the existing object, and sends it to the server. As a result, the fields which were removed from the new object (compared to the
+// last applied configuration) are removed on the cluster, while the fields which were added by other parties are retained.
+// The returned operation says if the object was updated (ie, its generation was incremented by the server), updated on its metadata only, or unchanged.
+func (c ApplyClient) patchObject(ctx context.Context, obj, existing client.Object, config applyObjectConfiguration) (ApplyOperation, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.Client.Scheme())
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get the GroupVersionKind of the resource '%v'", obj)
+	}
+	// if the last applied configuration was stored as a hash only, then there's no original configuration
+	// and no field can be removed from the existing object
+	var original []byte
+	if lastApplied, found := existing.GetAnnotations()[LastAppliedConfigurationAnnotationKey]; found {
+		if original, err = decodeConfiguration(lastApplied); err != nil {
+			return "", errors.Wrapf(err, "unable to read the last applied configuration of the resource '%v'", existing)
+		}
+	}
+	modified, err := marshalObjectContent(obj)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to marshal the resource '%v'", obj)
+	}
+	current, err := marshalObjectContent(existing)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to marshal the resource '%v'", existing)
+	}
+	patchType, patch, err := createThreeWayMergePatch(gvk, original, modified, current)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to compute the patch for the resource '%v'", obj)
+	}
+	if string(patch) == "{}" {
+		// nothing to change, but let's make sure that the caller gets the latest version of the resource
+		obj.SetResourceVersion(existing.GetResourceVersion())
+		obj.SetGeneration(existing.GetGeneration())
+		return ApplyUnchanged, nil
+	}
+	var opts []client.PatchOption
+	if config.dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	if err := c.Client.Patch(ctx, obj, client.RawPatch(patchType, patch), opts...); err != nil {
+		return "", errors.Wrapf(err, "unable to patch the resource '%v'", obj)
+	}
+	return updateOperation(existing, obj), nil
+}
+
+// createThreeWayMergePatch returns a strategic merge patch for the Kubernetes built-in types, or a JSON merge patch for all other types
+func createThreeWayMergePatch(gvk schema.GroupVersionKind, original, modified, current []byte) (types.PatchType, []byte, error) {
+	preconditions := []mergepatch.PreconditionFunc{
+		mergepatch.RequireKeyUnchanged("apiVersion"),
+		mergepatch.RequireKeyUnchanged("kind"),
+		mergepatch.RequireMetadataKeyUnchanged("name"),
+	}
+	versionedObject, err := builtInScheme.New(gvk)
+	switch {
+	case runtime.IsNotRegisteredError(err):
+		patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, current, preconditions...)
+		return types.MergePatchType, patch, err
+	case err != nil:
+		return "", nil, err
+	default:
+		lookupPatchMeta, err := strategicpatch.NewPatchMetaFromStruct(versionedObject)
+		if err != nil {
+			return "", nil, err
+		}
+		patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, current, lookupPatchMeta, true, preconditions...)
+		return types.StrategicMergePatchType, patch, err
+	}
+}
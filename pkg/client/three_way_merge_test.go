@@ -0,0 +1,159 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyObjectWithThreeWayMergePatch(t *testing.T) {
+	// given
+	addToScheme(t)
+
+	t.Run("for built-in types", func(t *testing.T) {
+		// given
+		namespacedName := types.NamespacedName{Namespace: "toolchain-host-operator", Name: "registration-service"}
+
+		t.Run("it should remove fields that were removed from the new object and retain fields that were added by others", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			patchTypes := recordPatchTypes(cli)
+			_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value", "second-param": "second-value"}), client.ThreeWayMergePatch(true))
+			require.NoError(t, err)
+			// some other party modifies the object
+			configMap := &corev1.ConfigMap{}
+			require.NoError(t, cli.Get(context.TODO(), namespacedName, configMap))
+			configMap.Labels = map[string]string{"added-by": "someone-else"}
+			configMap.Data["third-param"] = "third-value"
+			require.NoError(t, cli.Update(context.TODO(), configMap))
+
+			// when
+			_, err = cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "modified-value"}), client.ThreeWayMergePatch(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []types.PatchType{types.StrategicMergePatchType}, *patchTypes)
+			configMap = &corev1.ConfigMap{}
+			require.NoError(t, cli.Get(context.TODO(), namespacedName, configMap))
+			assert.Equal(t, map[string]string{
+				"first-param": "modified-value",
+				"third-param": "third-value",
+			}, configMap.Data)
+			assert.Equal(t, "someone-else", configMap.Labels["added-by"])
+			assert.Contains(t, configMap.Annotations[client.LastAppliedConfigurationAnnotationKey], "modified-value")
+		})
+
+		t.Run("it should not patch when object did not change", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			patchTypes := recordPatchTypes(cli)
+			_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}), client.ThreeWayMergePatch(true))
+			require.NoError(t, err)
+
+			// when
+			result, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}), client.ThreeWayMergePatch(true), client.ForceUpdate(true))
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, result.CreatedOrUpdated())
+			assert.Empty(t, *patchTypes)
+		})
+
+		t.Run("it should restore fields that were modified by others when forcing the update", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			patchTypes := recordPatchTypes(cli)
+			_, err := cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}), client.ThreeWayMergePatch(true))
+			require.NoError(t, err)
+			configMap := &corev1.ConfigMap{}
+			require.NoError(t, cli.Get(context.TODO(), namespacedName, configMap))
+			configMap.Data["first-param"] = "changed-by-someone-else"
+			require.NoError(t, cli.Update(context.TODO(), configMap))
+
+			// when
+			_, err = cl.ApplyObject(newConfigMap("toolchain-host-operator", "registration-service", map[string]string{"first-param": "first-value"}), client.ThreeWayMergePatch(true), client.ForceUpdate(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []types.PatchType{types.StrategicMergePatchType}, *patchTypes)
+			configMap = &corev1.ConfigMap{}
+			require.NoError(t, cli.Get(context.TODO(), namespacedName, configMap))
+			assert.Equal(t, "first-value", configMap.Data["first-param"])
+		})
+	})
+
+	t.Run("for custom resources", func(t *testing.T) {
+		// given
+		newSpace := func(annotations map[string]string, roles ...string) *toolchainv1alpha1.Space {
+			return &toolchainv1alpha1.Space{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "john",
+					Namespace:   "toolchain-host-operator",
+					Annotations: annotations,
+				},
+				Spec: toolchainv1alpha1.SpaceSpec{
+					TierName:           "base",
+					TargetClusterRoles: roles,
+				},
+			}
+		}
+		namespacedName := types.NamespacedName{Namespace: "toolchain-host-operator", Name: "john"}
+
+		t.Run("it should use a JSON merge patch", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			patchTypes := recordPatchTypes(cli)
+			_, err := cl.ApplyObject(newSpace(map[string]string{"foo": "bar"}, "tenant"), client.ThreeWayMergePatch(true))
+			require.NoError(t, err)
+			// some other party modifies the object
+			space := &toolchainv1alpha1.Space{}
+			require.NoError(t, cli.Get(context.TODO(), namespacedName, space))
+			space.Annotations["added-by"] = "someone-else"
+			space.Spec.TargetCluster = "member-1"
+			require.NoError(t, cli.Update(context.TODO(), space))
+
+			// when
+			_, err = cl.ApplyObject(newSpace(nil), client.ThreeWayMergePatch(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []types.PatchType{types.MergePatchType}, *patchTypes)
+			space = &toolchainv1alpha1.Space{}
+			require.NoError(t, cli.Get(context.TODO(), namespacedName, space))
+			assert.Equal(t, "member-1", space.Spec.TargetCluster)
+			assert.Equal(t, "base", space.Spec.TierName)
+			assert.Empty(t, space.Spec.TargetClusterRoles)
+			assert.NotContains(t, space.Annotations, "foo")
+			assert.Equal(t, "someone-else", space.Annotations["added-by"])
+		})
+	})
+
+	t.Run("when patch fails, it should return an error", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		_, err := cl.ApplyObject(newSA(), client.ThreeWayMergePatch(true))
+		require.NoError(t, err)
+		cli.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			return errors.New("mock error")
+		}
+		sa := newSA()
+		sa.Labels = map[string]string{"foo": "bar"}
+
+		// when
+		result, err := cl.ApplyObject(sa, client.ThreeWayMergePatch(true))
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to patch the resource")
+		assert.False(t, result.CreatedOrUpdated())
+	})
+}
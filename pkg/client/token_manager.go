@@ -0,0 +1,288 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+	authv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultTokenExpiration the default expiration of the tokens requested by the TokenManager
+	DefaultTokenExpiration = time.Hour
+	// DefaultTokenRefreshRatio the default ratio of the lifetime of a token after which the token is refreshed
+	DefaultTokenRefreshRatio = 0.8
+	// tokenRotationRetryInterval the minimum interval between two rotations of the token stored in a secret
+	tokenRotationRetryInterval = time.Second
+)
+
+// TokenManager creates tokens for ServiceAccounts (using TokenRequests) and caches them until they need to be refreshed,
+// ie, once a given ratio of their lifetime elapsed (see `TokenRefreshRatio`), so that the returned tokens never expire silently.
+// The expiry of the tokens is read from the `exp` claim of the JWT.
+type TokenManager struct {
+	restClient   *rest.RESTClient
+	refreshRatio float64
+	lock         sync.Mutex
+	tokens       map[string]cachedToken
+	// requests collapses the concurrent requests of the same token
+	requests singleflight.Group
+}
+
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+	refreshAt time.Time
+}
+
+// TokenManagerOption an option to configure the TokenManager
+type TokenManagerOption func(*TokenManager)
+
+// TokenRefreshRatio sets the ratio of the lifetime of a token after which the token is refreshed (default: `DefaultTokenRefreshRatio`).
+// The ratio must be strictly between 0 and 1, otherwise it is ignored.
+func TokenRefreshRatio(ratio float64) TokenManagerOption {
+	return func(m *TokenManager) {
+		if ratio <= 0 || ratio >= 1 {
+			log.Info("ignoring the token refresh ratio since it is not between 0 and 1", "ratio", ratio)
+			return
+		}
+		m.refreshRatio = ratio
+	}
+}
+
+// NewTokenManager returns a new TokenManager which requests the tokens using the given REST client
+func NewTokenManager(restClient *rest.RESTClient, options ...TokenManagerOption) *TokenManager {
+	m := &TokenManager{
+		restClient:   restClient,
+		refreshRatio: DefaultTokenRefreshRatio,
+		tokens:       map[string]cachedToken{},
+	}
+	for _, configure := range options {
+		configure(m)
+	}
+	return m
+}
+
+// TokenOption an option of the token to request
+type TokenOption func(spec *authv1.TokenRequestSpec)
+
+// TokenExpiration sets the requested duration of validity of the token (default: `DefaultTokenExpiration`).
+// Note that the server may return a token with a different duration.
+func TokenExpiration(expiration time.Duration) TokenOption {
+	return func(spec *authv1.TokenRequestSpec) {
+		spec.ExpirationSeconds = pointer.Int64(int64(expiration.Seconds()))
+	}
+}
+
+// TokenAudiences sets the intended audiences of the token (default: the audience of the API server)
+func TokenAudiences(audiences ...string) TokenOption {
+	return func(spec *authv1.TokenRequestSpec) {
+		spec.Audiences = audiences
+	}
+}
+
+// TokenBoundObjectRef binds the token to the given object (eg, a Secret or a Pod), so that the token is invalidated
+// as soon as the object is deleted
+func TokenBoundObjectRef(ref authv1.BoundObjectReference) TokenOption {
+	return func(spec *authv1.TokenRequestSpec) {
+		spec.BoundObjectRef = &ref
+	}
+}
+
+// GetToken returns a token for the given ServiceAccount. The token is taken from the cache, unless it needs to be refreshed.
+func (m *TokenManager) GetToken(ctx context.Context, serviceAccount types.NamespacedName, options ...TokenOption) (string, error) {
+	token, err := m.getToken(ctx, serviceAccount, options...)
+	if err != nil {
+		return "", err
+	}
+	return token.token, nil
+}
+
+func (m *TokenManager) getToken(ctx context.Context, serviceAccount types.NamespacedName, options ...TokenOption) (cachedToken, error) {
+	spec := authv1.TokenRequestSpec{
+		ExpirationSeconds: pointer.Int64(int64(DefaultTokenExpiration.Seconds())),
+	}
+	for _, configure := range options {
+		configure(&spec)
+	}
+	key := tokenCacheKey(serviceAccount, spec)
+
+	if token, found := m.getCachedToken(key); found {
+		return token, nil
+	}
+	// the lock is not held while the token is requested, so that the other tokens can still be retrieved in the meantime
+	token, err, _ := m.requests.Do(key, func() (interface{}, error) {
+		// the token may have been refreshed by a concurrent request in the meantime
+		if token, found := m.getCachedToken(key); found {
+			return token, nil
+		}
+		token, err := m.requestToken(ctx, serviceAccount, spec)
+		if err != nil {
+			return nil, err
+		}
+		m.storeToken(key, token)
+		return token, nil
+	})
+	if err != nil {
+		return cachedToken{}, err
+	}
+	return token.(cachedToken), nil
+}
+
+// getCachedToken returns the token with the given key from the cache, unless it needs to be refreshed
+func (m *TokenManager) getCachedToken(key string) (cachedToken, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if token, found := m.tokens[key]; found && time.Now().Before(token.refreshAt) {
+		return token, true
+	}
+	return cachedToken{}, false
+}
+
+// storeToken caches the given token under the given key, and evicts the expired tokens from the cache
+// (eg, the tokens bound to objects which were deleted since then, and which are never requested again)
+func (m *TokenManager) storeToken(key string, token cachedToken) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := time.Now()
+	for k, t := range m.tokens {
+		if !now.Before(t.expiresAt) {
+			delete(m.tokens, k)
+		}
+	}
+	m.tokens[key] = token
+}
+
+// requestToken creates a new token for the given ServiceAccount with the given spec
+func (m *TokenManager) requestToken(ctx context.Context, serviceAccount types.NamespacedName, spec authv1.TokenRequestSpec) (cachedToken, error) {
+	requestedAt := time.Now()
+	status, err := createTokenRequest(ctx, m.restClient, serviceAccount, spec)
+	if err != nil {
+		return cachedToken{}, errors.Wrapf(err, "unable to create a token for the service account '%s'", serviceAccount)
+	}
+	token := cachedToken{
+		token:     status.Token,
+		expiresAt: status.ExpirationTimestamp.Time,
+	}
+	issuedAt := requestedAt
+	if claims, err := decodeTokenClaims(status.Token); err != nil {
+		log.Info("unable to read the expiry of the token, using the expiration timestamp of the token request instead", "serviceAccount", serviceAccount.String(), "error", err.Error())
+	} else {
+		token.expiresAt = time.Unix(claims.ExpiresAt, 0)
+		if claims.IssuedAt > 0 {
+			issuedAt = time.Unix(claims.IssuedAt, 0)
+		}
+	}
+	token.refreshAt = issuedAt.Add(time.Duration(float64(token.expiresAt.Sub(issuedAt)) * m.refreshRatio))
+	return token, nil
+}
+
+// WriteTokenToSecret stores a token for the given ServiceAccount (see `GetToken`) under the given key of the given Secret,
+// eg, the `token` key of the secret of a ToolchainCluster. The Secret is created if it does not exist yet.
+// Returns `true` if the Secret was created or updated.
+func (m *TokenManager) WriteTokenToSecret(ctx context.Context, cl client.Client, serviceAccount, secret types.NamespacedName, key string, options ...TokenOption) (bool, error) {
+	token, err := m.GetToken(ctx, serviceAccount, options...)
+	if err != nil {
+		return false, err
+	}
+	existing := &corev1.Secret{}
+	if err := cl.Get(ctx, secret, existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "unable to get the secret '%s'", secret)
+		}
+		if err := cl.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: secret.Namespace,
+				Name:      secret.Name,
+			},
+			Data: map[string][]byte{
+				key: []byte(token),
+			},
+		}); err != nil {
+			return false, errors.Wrapf(err, "unable to create the secret '%s'", secret)
+		}
+		return true, nil
+	}
+	if string(existing.Data[key]) == token {
+		return false, nil
+	}
+	if existing.Data == nil {
+		existing.Data = map[string][]byte{}
+	}
+	existing.Data[key] = []byte(token)
+	if err := cl.Update(ctx, existing); err != nil {
+		return false, errors.Wrapf(err, "unable to update the secret '%s'", secret)
+	}
+	return true, nil
+}
+
+// RunTokenRotation keeps the token stored under the given key of the given Secret up-to-date (see `WriteTokenToSecret`)
+// until the given context is done: the token is rotated as soon as it needs to be refreshed. Failures are logged and retried.
+func (m *TokenManager) RunTokenRotation(ctx context.Context, cl client.Client, serviceAccount, secret types.NamespacedName, key string, options ...TokenOption) {
+	for {
+		next := tokenRotationRetryInterval
+		if _, err := m.WriteTokenToSecret(ctx, cl, serviceAccount, secret, key, options...); err != nil {
+			log.Error(err, "unable to rotate the token stored in the secret", "secret", secret.String())
+		} else if token, err := m.getToken(ctx, serviceAccount, options...); err == nil && time.Until(token.refreshAt) > next {
+			next = time.Until(token.refreshAt)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next):
+		}
+	}
+}
+
+// tokenCacheKey returns the key of the tokens of the given ServiceAccount with the given spec
+func tokenCacheKey(serviceAccount types.NamespacedName, spec authv1.TokenRequestSpec) string {
+	audiences := append([]string{}, spec.Audiences...)
+	sort.Strings(audiences)
+	key := fmt.Sprintf("%s|%s", serviceAccount, strings.Join(audiences, ","))
+	if spec.ExpirationSeconds != nil {
+		key += fmt.Sprintf("|%d", *spec.ExpirationSeconds)
+	}
+	if ref := spec.BoundObjectRef; ref != nil {
+		key += fmt.Sprintf("|%s/%s/%s/%s", ref.APIVersion, ref.Kind, ref.Name, ref.UID)
+	}
+	return key
+}
+
+type tokenClaims struct {
+	ExpiresAt int64 `json:"exp"`
+	IssuedAt  int64 `json:"iat"`
+}
+
+// decodeTokenClaims reads the claims of the given JWT (without verifying its signature)
+func decodeTokenClaims(token string) (tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return tokenClaims{}, fmt.Errorf("the token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return tokenClaims{}, errors.Wrap(err, "unable to decode the payload of the token")
+	}
+	claims := tokenClaims{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return tokenClaims{}, errors.Wrap(err, "unable to decode the claims of the token")
+	}
+	if claims.ExpiresAt == 0 {
+		return tokenClaims{}, fmt.Errorf("the token has no expiry")
+	}
+	return claims, nil
+}
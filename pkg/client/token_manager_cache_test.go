@@ -0,0 +1,23 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenCacheEviction(t *testing.T) {
+	// given
+	manager := NewTokenManager(nil)
+	manager.tokens["expired"] = cachedToken{token: "expired", expiresAt: time.Now().Add(-time.Minute), refreshAt: time.Now().Add(-time.Hour)}
+	manager.tokens["to-refresh"] = cachedToken{token: "to-refresh", expiresAt: time.Now().Add(time.Minute), refreshAt: time.Now().Add(-time.Minute)}
+
+	// when
+	manager.storeToken("new", cachedToken{token: "new", expiresAt: time.Now().Add(time.Hour), refreshAt: time.Now().Add(time.Minute)})
+
+	// then
+	assert.NotContains(t, manager.tokens, "expired")
+	assert.Contains(t, manager.tokens, "to-refresh") // not expired yet
+	assert.Contains(t, manager.tokens, "new")
+}
@@ -0,0 +1,347 @@
+package client_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	. "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	authv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+func TestTokenManager(t *testing.T) {
+	// given
+	const apiEndpoint = "https://api.example.com"
+	const tokenPath = "api/v1/namespaces/toolchain-member-operator/serviceaccounts/toolchaincluster-member/token"
+	serviceAccount := types.NamespacedName{Namespace: "toolchain-member-operator", Name: "toolchaincluster-member"}
+	newRESTClient := func(t *testing.T) *rest.RESTClient {
+		cl, err := NewRESTClient("secret_token", apiEndpoint)
+		require.NoError(t, err)
+		cl.Client.Transport = gock.DefaultTransport // make sure that the underlying client's request are intercepted by Gock
+		t.Cleanup(gock.OffAll)
+		return cl
+	}
+	// mockTokenRequest registers a single response with the given token
+	mockTokenRequest := func(t *testing.T, token string) {
+		body, err := json.Marshal(&authv1.TokenRequest{
+			Status: authv1.TokenRequestStatus{
+				Token:               token,
+				ExpirationTimestamp: metav1.NewTime(time.Now().Add(time.Hour)),
+			},
+		})
+		require.NoError(t, err)
+		gock.New(apiEndpoint).
+			Post(tokenPath).
+			Reply(http.StatusOK).
+			BodyString(string(body))
+	}
+	// observeTokenRequests records the spec of the intercepted token requests
+	observeTokenRequests := func(t *testing.T) *[]authv1.TokenRequestSpec {
+		requests := &[]authv1.TokenRequestSpec{}
+		gock.Observe(func(req *http.Request, _ gock.Mock) {
+			tokenRequest := &authv1.TokenRequest{}
+			require.NoError(t, json.NewDecoder(req.Body).Decode(tokenRequest))
+			*requests = append(*requests, tokenRequest.Spec)
+		})
+		t.Cleanup(func() {
+			gock.Observe(nil)
+		})
+		return requests
+	}
+
+	t.Run("should cache token", func(t *testing.T) {
+		// given
+		manager := client.NewTokenManager(newRESTClient(t))
+		token := newJWT(t, time.Now(), time.Now().Add(time.Hour))
+		requests := observeTokenRequests(t)
+		mockTokenRequest(t, token)
+
+		// when
+		first, err := manager.GetToken(context.TODO(), serviceAccount)
+		require.NoError(t, err)
+		second, err := manager.GetToken(context.TODO(), serviceAccount)
+		require.NoError(t, err)
+
+		// then
+		assert.Equal(t, token, first)
+		assert.Equal(t, token, second)
+		require.Len(t, *requests, 1)
+		require.NotNil(t, (*requests)[0].ExpirationSeconds)
+		assert.Equal(t, int64(3600), *(*requests)[0].ExpirationSeconds)
+	})
+
+	t.Run("should refresh token before expiry", func(t *testing.T) {
+		// given
+		manager := client.NewTokenManager(newRESTClient(t))
+		// 85% of the lifetime of the token elapsed
+		expiring := newJWT(t, time.Now().Add(-85*time.Minute), time.Now().Add(15*time.Minute))
+		refreshed := newJWT(t, time.Now(), time.Now().Add(100*time.Minute))
+		mockTokenRequest(t, expiring)
+		mockTokenRequest(t, refreshed)
+
+		// when
+		first, err := manager.GetToken(context.TODO(), serviceAccount)
+		require.NoError(t, err)
+		second, err := manager.GetToken(context.TODO(), serviceAccount)
+		require.NoError(t, err)
+
+		// then
+		assert.Equal(t, expiring, first)
+		assert.Equal(t, refreshed, second)
+	})
+
+	t.Run("should refresh token with custom ratio", func(t *testing.T) {
+		// given
+		manager := client.NewTokenManager(newRESTClient(t), client.TokenRefreshRatio(0.5))
+		// 60% of the lifetime of the token elapsed
+		expiring := newJWT(t, time.Now().Add(-60*time.Minute), time.Now().Add(40*time.Minute))
+		refreshed := newJWT(t, time.Now(), time.Now().Add(100*time.Minute))
+		mockTokenRequest(t, expiring)
+		mockTokenRequest(t, refreshed)
+
+		// when
+		_, err := manager.GetToken(context.TODO(), serviceAccount)
+		require.NoError(t, err)
+		second, err := manager.GetToken(context.TODO(), serviceAccount)
+		require.NoError(t, err)
+
+		// then
+		assert.Equal(t, refreshed, second)
+	})
+
+	t.Run("should ignore invalid ratio", func(t *testing.T) {
+		for _, ratio := range []float64{-0.5, 0, 1, 1.5} {
+			t.Run(fmt.Sprintf("%v", ratio), func(t *testing.T) {
+				// given
+				manager := client.NewTokenManager(newRESTClient(t), client.TokenRefreshRatio(ratio))
+				// 85% of the lifetime of the token elapsed, so the token is refreshed with the default ratio
+				expiring := newJWT(t, time.Now().Add(-85*time.Minute), time.Now().Add(15*time.Minute))
+				refreshed := newJWT(t, time.Now(), time.Now().Add(100*time.Minute))
+				mockTokenRequest(t, expiring)
+				mockTokenRequest(t, refreshed)
+
+				// when
+				first, err := manager.GetToken(context.TODO(), serviceAccount)
+				require.NoError(t, err)
+				second, err := manager.GetToken(context.TODO(), serviceAccount)
+				require.NoError(t, err)
+				third, err := manager.GetToken(context.TODO(), serviceAccount)
+				require.NoError(t, err)
+
+				// then
+				assert.Equal(t, expiring, first)
+				assert.Equal(t, refreshed, second)
+				assert.Equal(t, refreshed, third)
+			})
+		}
+	})
+
+	t.Run("should use expiration timestamp when token is not a JWT", func(t *testing.T) {
+		// given
+		manager := client.NewTokenManager(newRESTClient(t))
+		mockTokenRequest(t, "opaque-token")
+
+		// when
+		first, err := manager.GetToken(context.TODO(), serviceAccount)
+		require.NoError(t, err)
+		second, err := manager.GetToken(context.TODO(), serviceAccount)
+		require.NoError(t, err)
+
+		// then
+		assert.Equal(t, "opaque-token", first)
+		assert.Equal(t, "opaque-token", second)
+	})
+
+	t.Run("should request token with audiences and bound object", func(t *testing.T) {
+		// given
+		manager := client.NewTokenManager(newRESTClient(t))
+		requests := observeTokenRequests(t)
+		hostToken := newJWT(t, time.Now(), time.Now().Add(time.Hour))
+		otherToken := newJWT(t, time.Now(), time.Now().Add(2*time.Hour))
+		mockTokenRequest(t, hostToken)
+		mockTokenRequest(t, otherToken)
+		boundRef := authv1.BoundObjectReference{Kind: "Secret", APIVersion: "v1", Name: "toolchaincluster-member", UID: "123"}
+
+		// when
+		first, err := manager.GetToken(context.TODO(), serviceAccount,
+			client.TokenAudiences("host", "api"), client.TokenBoundObjectRef(boundRef), client.TokenExpiration(24*time.Hour))
+		require.NoError(t, err)
+		// the order of the audiences does not matter
+		cached, err := manager.GetToken(context.TODO(), serviceAccount,
+			client.TokenAudiences("api", "host"), client.TokenBoundObjectRef(boundRef), client.TokenExpiration(24*time.Hour))
+		require.NoError(t, err)
+		other, err := manager.GetToken(context.TODO(), serviceAccount, client.TokenAudiences("other"))
+		require.NoError(t, err)
+
+		// then
+		assert.Equal(t, hostToken, first)
+		assert.Equal(t, hostToken, cached)
+		assert.Equal(t, otherToken, other)
+		require.Len(t, *requests, 2)
+		assert.Equal(t, []string{"host", "api"}, (*requests)[0].Audiences)
+		assert.Equal(t, &boundRef, (*requests)[0].BoundObjectRef)
+		require.NotNil(t, (*requests)[0].ExpirationSeconds)
+		assert.Equal(t, int64(86400), *(*requests)[0].ExpirationSeconds)
+		assert.Equal(t, []string{"other"}, (*requests)[1].Audiences)
+		assert.Nil(t, (*requests)[1].BoundObjectRef)
+	})
+
+	t.Run("should fail when token cannot be created", func(t *testing.T) {
+		// given
+		manager := client.NewTokenManager(newRESTClient(t))
+		gock.New(apiEndpoint).Post(tokenPath).Reply(http.StatusForbidden)
+
+		// when
+		_, err := manager.GetToken(context.TODO(), serviceAccount)
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to create a token for the service account 'toolchain-member-operator/toolchaincluster-member'")
+	})
+
+	t.Run("concurrent requests", func(t *testing.T) {
+		// given
+		token := newJWT(t, time.Now(), time.Now().Add(time.Hour))
+		release := make(chan struct{})
+		var lock sync.Mutex
+		requests := map[string]int{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			requests[r.URL.Path]++
+			lock.Unlock()
+			if strings.Contains(r.URL.Path, "/slow/") {
+				<-release
+			}
+			w.Header().Set("Content-Type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(&authv1.TokenRequest{
+				Status: authv1.TokenRequestStatus{Token: token},
+			}))
+		}))
+		t.Cleanup(server.Close)
+		restClient, err := NewRESTClient("secret_token", server.URL)
+		require.NoError(t, err)
+		manager := client.NewTokenManager(restClient)
+		slow := types.NamespacedName{Namespace: "toolchain-member-operator", Name: "slow"}
+		var releaseOnce sync.Once
+		defer releaseOnce.Do(func() { close(release) })
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				slowToken, err := manager.GetToken(context.TODO(), slow)
+				assert.NoError(t, err)
+				assert.Equal(t, token, slowToken)
+			}()
+		}
+		require.Eventually(t, func() bool {
+			lock.Lock()
+			defer lock.Unlock()
+			return requests["/api/v1/namespaces/toolchain-member-operator/serviceaccounts/slow/token"] == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// when
+		other, err := manager.GetToken(context.TODO(), serviceAccount)
+
+		// then
+		require.NoError(t, err) // not blocked by the pending request
+		assert.Equal(t, token, other)
+		releaseOnce.Do(func() { close(release) })
+		wg.Wait()
+		assert.Equal(t, 1, requests["/api/v1/namespaces/toolchain-member-operator/serviceaccounts/slow/token"]) // the concurrent requests were collapsed
+	})
+
+	t.Run("secret", func(t *testing.T) {
+		secretName := types.NamespacedName{Namespace: "toolchain-member-operator", Name: "toolchaincluster-host"}
+		getToken := func(t *testing.T, cl *FakeClient) string {
+			secret := &corev1.Secret{}
+			require.NoError(t, cl.Get(context.TODO(), secretName, secret))
+			return string(secret.Data["token"])
+		}
+
+		t.Run("should create, keep and update secret", func(t *testing.T) {
+			// given
+			manager := client.NewTokenManager(newRESTClient(t))
+			cl := NewFakeClient(t)
+			expiring := newJWT(t, time.Now(), time.Now().Add(time.Hour))
+			refreshed := newJWT(t, time.Now(), time.Now().Add(2*time.Hour))
+			mockTokenRequest(t, expiring)
+			mockTokenRequest(t, refreshed)
+
+			// when
+			created, err := manager.WriteTokenToSecret(context.TODO(), cl, serviceAccount, secretName, "token")
+			require.NoError(t, err)
+			createdToken := getToken(t, cl)
+			unchanged, err := manager.WriteTokenToSecret(context.TODO(), cl, serviceAccount, secretName, "token")
+			require.NoError(t, err)
+			// a token with another expiration is requested
+			updated, err := manager.WriteTokenToSecret(context.TODO(), cl, serviceAccount, secretName, "token", client.TokenExpiration(2*time.Hour))
+			require.NoError(t, err)
+
+			// then
+			assert.True(t, created)
+			assert.Equal(t, expiring, createdToken)
+			assert.False(t, unchanged)
+			assert.True(t, updated)
+			assert.Equal(t, refreshed, getToken(t, cl))
+		})
+
+		t.Run("should rotate token in secret", func(t *testing.T) {
+			// given
+			manager := client.NewTokenManager(newRESTClient(t))
+			cl := NewFakeClient(t)
+			expiring := newJWT(t, time.Now(), time.Now().Add(2*time.Second))
+			refreshed := newJWT(t, time.Now(), time.Now().Add(time.Hour))
+			mockTokenRequest(t, expiring)
+			mockTokenRequest(t, refreshed)
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+
+			// when
+			done := make(chan struct{})
+			go func() {
+				manager.RunTokenRotation(ctx, cl, serviceAccount, secretName, "token")
+				close(done)
+			}()
+
+			// then
+			require.Eventually(t, func() bool {
+				secret := &corev1.Secret{}
+				return cl.Get(context.TODO(), secretName, secret) == nil && string(secret.Data["token"]) == refreshed
+			}, 5*time.Second, 50*time.Millisecond)
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the token rotation did not stop")
+			}
+		})
+	})
+}
+
+// newJWT returns an unsigned JWT with the given issue and expiry times
+func newJWT(t *testing.T, issuedAt, expiresAt time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	payload, err := json.Marshal(map[string]interface{}{
+		"iat": issuedAt.Unix(),
+		"exp": expiresAt.Unix(),
+		"sub": fmt.Sprintf("system:serviceaccount:toolchain-member-operator:toolchaincluster-member-%d", issuedAt.UnixNano()),
+	})
+	require.NoError(t, err)
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
@@ -1,19 +1,55 @@
 package cluster
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"golang.org/x/sync/singleflight"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
 )
 
-var clusterCache = toolchainClusterClients{clusters: map[string]*CachedToolchainCluster{}}
+// clusterCache the default cache of the clusters, used by the package-level funcs (eg, `GetHostCluster`, `GetMemberClusters`)
+// and by the services created with `NewToolchainClusterService`
+var clusterCache = NewClusterCache()
 
-type toolchainClusterClients struct {
-	sync.RWMutex
+// ClusterCache a cache of the clients of the ToolchainClusters. It's filled by a `ToolchainClusterService`
+// (see `NewToolchainClusterServiceWithCache`), which is also used to refresh the cache when a cluster is missing.
+// Several caches can be used in the same process, eg, by different services or parallel tests.
+// The concurrent refreshes are collapsed, and the refreshes are throttled (see `MinRefreshInterval` and `NegativeCacheTTL`).
+type ClusterCache struct {
+	lock         sync.RWMutex
 	clusters     map[string]*CachedToolchainCluster
 	refreshCache func()
+	// refreshGroup collapses the concurrent refreshes
+	refreshGroup       singleflight.Group
+	lastRefresh        time.Time
+	minRefreshInterval time.Duration
+	// unknownClusters the names of the clusters which were still missing after a refresh, with the time of the refresh
+	unknownClusters  map[string]time.Time
+	negativeCacheTTL time.Duration
+}
+
+// NewClusterCache returns a new, empty cache of clusters configured with the given options
+func NewClusterCache(options ...ClusterCacheOption) *ClusterCache {
+	cache := &ClusterCache{
+		clusters:           map[string]*CachedToolchainCluster{},
+		minRefreshInterval: DefaultMinRefreshInterval,
+		unknownClusters:    map[string]time.Time{},
+		negativeCacheTTL:   DefaultNegativeCacheTTL,
+	}
+	for _, configure := range options {
+		configure(cache)
+	}
+	return cache
+}
+
+// DefaultClusterCache returns the default cache of the clusters, used by the package-level funcs
+func DefaultClusterCache() *ClusterCache {
+	return clusterCache
 }
 
 type Config struct {
@@ -33,6 +69,10 @@ type Config struct {
 	// then the OwnerClusterName has a name of the member - it has to be same name as the name
 	// that is used for identifying the member in a Host cluster
 	OwnerClusterName string
+	// SecretResourceVersion the resourceVersion of the Secret (referenced by the ToolchainCluster) the credentials were read from
+	SecretResourceVersion string
+	// ProxyURL the URL of the proxy used to connect to the cluster, if any (defined in the kubeconfig or with the `ClientProxyURLAnnotation`)
+	ProxyURL string
 
 	// Labels contains all the labels of the corresponding ToolchainCluster.
 	// They will be used for filtering ToolchainCluster's based on a given list of cluster-role labels.
@@ -44,32 +84,69 @@ type CachedToolchainCluster struct {
 	*Config
 	// Client is the kube client for the cluster.
 	Client client.Client
+	// CachedReader is the reader backed by the informers of the cluster if the cached clients are enabled
+	// (see `NewToolchainClusterServiceWithCachedClients`), or the Client otherwise.
+	CachedReader client.Reader
 	// ClusterStatus is the cluster result as of the last health check probe.
 	ClusterStatus *toolchainv1alpha1.ToolchainClusterStatus
+	// cluster is the controller-runtime cluster providing the cached reader, if the cached clients are enabled
+	cluster *startedCluster
+}
+
+// startedCluster a started controller-runtime cluster, along with the func to stop it
+type startedCluster struct {
+	crcluster.Cluster
+	stop context.CancelFunc
 }
 
-func (c *toolchainClusterClients) addCachedToolchainCluster(cluster *CachedToolchainCluster) {
-	c.Lock()
-	defer c.Unlock()
+func (c *ClusterCache) addCachedToolchainCluster(cluster *CachedToolchainCluster) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if existing, found := c.clusters[cluster.Name]; found && existing.cluster != nil && existing.cluster != cluster.cluster {
+		// the client was rebuilt
+		existing.cluster.stop()
+	}
 	c.clusters[cluster.Name] = cluster
+	delete(c.unknownClusters, cluster.Name)
 }
 
-func (c *toolchainClusterClients) deleteCachedToolchainCluster(name string) {
-	c.Lock()
-	defer c.Unlock()
+func (c *ClusterCache) deleteCachedToolchainCluster(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if existing, found := c.clusters[name]; found && existing.cluster != nil {
+		existing.cluster.stop()
+	}
 	delete(c.clusters, name)
 }
 
-func (c *toolchainClusterClients) getCachedToolchainCluster(name string, canRefreshCache bool) (*CachedToolchainCluster, bool) {
-	c.RLock()
-	defer c.RUnlock()
+// deleteCachedToolchainClusterIfStartedWith deletes the cluster with the given name only if it still uses the given started cluster
+func (c *ClusterCache) deleteCachedToolchainClusterIfStartedWith(name string, cluster *startedCluster) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if existing, found := c.clusters[name]; found && existing.cluster == cluster {
+		existing.cluster.stop()
+		delete(c.clusters, name)
+	}
+}
+
+func (c *ClusterCache) getCachedToolchainCluster(name string, canRefreshCache bool) (*CachedToolchainCluster, bool) {
+	c.lock.RLock()
 	_, ok := c.clusters[name]
-	if !ok && canRefreshCache && c.refreshCache != nil {
-		c.RUnlock()
-		c.refreshCache()
-		c.RLock()
+	c.lock.RUnlock()
+	if ok || !canRefreshCache || c.isUnknown(name) {
+		c.lock.RLock()
+		defer c.lock.RUnlock()
+		cluster, ok := c.clusters[name]
+		return cluster, ok
 	}
+	refreshed := c.refresh()
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	cluster, ok := c.clusters[name]
+	if !ok && refreshed {
+		// the cluster is unknown, so there is no need to refresh the cache again for a while
+		c.unknownClusters[name] = time.Now()
+	}
 	return cluster, ok
 }
 
@@ -81,9 +158,9 @@ var Ready Condition = func(cluster *CachedToolchainCluster) bool {
 	return IsReady(cluster.ClusterStatus)
 }
 
-func (c *toolchainClusterClients) getCachedToolchainClustersByType(clusterType Type, conditions ...Condition) []*CachedToolchainCluster {
-	c.RLock()
-	defer c.RUnlock()
+func (c *ClusterCache) getCachedToolchainClustersByType(clusterType Type, conditions ...Condition) []*CachedToolchainCluster {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	return Filter(clusterType, c.clusters, conditions...)
 }
 func Filter(clusterType Type, clusters map[string]*CachedToolchainCluster, conditions ...Condition) []*CachedToolchainCluster {
@@ -103,8 +180,36 @@ clusters:
 }
 
 // GetCachedToolchainCluster returns a kube client for the cluster (with the given name) and info if the client exists
+func (c *ClusterCache) GetCachedToolchainCluster(name string) (*CachedToolchainCluster, bool) {
+	return c.getCachedToolchainCluster(name, true)
+}
+
+// GetHostCluster returns the kube client for the host cluster from the cache and info if such a client exists
+func (c *ClusterCache) GetHostCluster() (*CachedToolchainCluster, bool) {
+	clusters := c.getCachedToolchainClustersByType(Host)
+	if len(clusters) == 0 {
+		c.refresh()
+		clusters = c.getCachedToolchainClustersByType(Host)
+		if len(clusters) == 0 {
+			return nil, false
+		}
+	}
+	return clusters[0], true
+}
+
+// GetMemberClusters returns the kube clients for the member clusters from the cache
+func (c *ClusterCache) GetMemberClusters(conditions ...Condition) []*CachedToolchainCluster {
+	clusters := c.getCachedToolchainClustersByType(Member, conditions...)
+	if len(clusters) == 0 {
+		c.refresh()
+		clusters = c.getCachedToolchainClustersByType(Member, conditions...)
+	}
+	return clusters
+}
+
+// GetCachedToolchainCluster returns a kube client for the cluster (with the given name) from the default cache and info if the client exists
 func GetCachedToolchainCluster(name string) (*CachedToolchainCluster, bool) {
-	return clusterCache.getCachedToolchainCluster(name, true)
+	return clusterCache.GetCachedToolchainCluster(name)
 }
 
 // GetHostClusterFunc a func that returns the Host cluster from the cache,
@@ -114,20 +219,10 @@ type GetHostClusterFunc func() (*CachedToolchainCluster, bool)
 // HostCluster the func to retrieve the host cluster
 var HostCluster GetHostClusterFunc = GetHostCluster
 
-// GetHostCluster returns the kube client for the host cluster from the cache of the clusters
+// GetHostCluster returns the kube client for the host cluster from the default cache of the clusters
 // and info if such a client exists
 func GetHostCluster() (*CachedToolchainCluster, bool) {
-	clusters := clusterCache.getCachedToolchainClustersByType(Host)
-	if len(clusters) == 0 {
-		if clusterCache.refreshCache != nil {
-			clusterCache.refreshCache()
-		}
-		clusters = clusterCache.getCachedToolchainClustersByType(Host)
-		if len(clusters) == 0 {
-			return nil, false
-		}
-	}
-	return clusters[0], true
+	return clusterCache.GetHostCluster()
 }
 
 // GetMemberClustersFunc a func that returns the member clusters from the cache
@@ -136,16 +231,9 @@ type GetMemberClustersFunc func(conditions ...Condition) []*CachedToolchainClust
 // MemberClusters the func to retrieve the member clusters
 var MemberClusters GetMemberClustersFunc = GetMemberClusters
 
-// GetMemberClusters returns the kube clients for the host clusters from the cache of the clusters
+// GetMemberClusters returns the kube clients for the member clusters from the default cache of the clusters
 func GetMemberClusters(conditions ...Condition) []*CachedToolchainCluster {
-	clusters := clusterCache.getCachedToolchainClustersByType(Member, conditions...)
-	if len(clusters) == 0 {
-		if clusterCache.refreshCache != nil {
-			clusterCache.refreshCache()
-		}
-		clusters = clusterCache.getCachedToolchainClustersByType(Member, conditions...)
-	}
-	return clusters
+	return clusterCache.GetMemberClusters(conditions...)
 }
 
 // Type is a cluster type (either host or member)
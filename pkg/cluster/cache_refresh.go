@@ -0,0 +1,118 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// DefaultMinRefreshInterval the default minimum interval between two refreshes of a cache of clusters
+	DefaultMinRefreshInterval = time.Second
+	// DefaultNegativeCacheTTL the default duration during which a cluster which was still missing after a refresh
+	// does not trigger a new refresh of the cache
+	DefaultNegativeCacheTTL = 10 * time.Second
+)
+
+// The values of the `result` label of the refresh metrics
+const (
+	// refreshExecuted the refresh was executed by the caller
+	refreshExecuted = "executed"
+	// refreshShared the caller waited for the refresh executed concurrently by another caller
+	refreshShared = "shared"
+	// refreshThrottled the refresh was skipped because the cache was refreshed recently, or because the cluster is known to be missing
+	refreshThrottled = "throttled"
+)
+
+var (
+	// cacheRefreshesTotal the number of refreshes of the caches of clusters, by result
+	cacheRefreshesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "toolchain_cluster_cache_refreshes_total",
+		Help: "Number of refreshes of the cluster cache requested on a cache miss, by result (executed, shared or throttled)",
+	}, []string{"result"})
+
+	// cacheRefreshDurationSeconds the duration of the refreshes of the caches of clusters
+	cacheRefreshDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "toolchain_cluster_cache_refresh_duration_seconds",
+		Help:    "Duration of the refreshes of the cluster cache",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(cacheRefreshesTotal, cacheRefreshDurationSeconds)
+}
+
+// ClusterCacheOption an option to configure a cache of clusters
+type ClusterCacheOption func(*ClusterCache)
+
+// MinRefreshInterval sets the minimum interval between two refreshes of the cache (default: `DefaultMinRefreshInterval`).
+// The refreshes requested during the interval are skipped.
+func MinRefreshInterval(interval time.Duration) ClusterCacheOption {
+	return func(c *ClusterCache) {
+		c.minRefreshInterval = interval
+	}
+}
+
+// NegativeCacheTTL sets the duration during which a cluster which was still missing after a refresh
+// does not trigger a new refresh of the cache (default: `DefaultNegativeCacheTTL`)
+func NegativeCacheTTL(ttl time.Duration) ClusterCacheOption {
+	return func(c *ClusterCache) {
+		c.negativeCacheTTL = ttl
+	}
+}
+
+func (c *ClusterCache) setRefreshCache(refreshCache func()) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.refreshCache = refreshCache
+	c.lastRefresh = time.Time{}
+	c.unknownClusters = map[string]time.Time{}
+}
+
+// refresh refreshes the cache with the func of the service which owns it (if any), unless the cache was refreshed recently.
+// The concurrent refreshes are collapsed into a single one.
+// Returns `true` if the cache was refreshed (by this caller or concurrently by another one).
+func (c *ClusterCache) refresh() bool {
+	c.lock.RLock()
+	refreshCache := c.refreshCache
+	throttled := !c.lastRefresh.IsZero() && time.Since(c.lastRefresh) < c.minRefreshInterval
+	c.lock.RUnlock()
+	if refreshCache == nil {
+		return false
+	}
+	if throttled {
+		cacheRefreshesTotal.WithLabelValues(refreshThrottled).Inc()
+		return false
+	}
+	executed := false
+	c.refreshGroup.Do("refresh", func() (interface{}, error) { // nolint:errcheck
+		executed = true
+		start := time.Now()
+		refreshCache()
+		cacheRefreshDurationSeconds.Observe(time.Since(start).Seconds())
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		c.lastRefresh = time.Now()
+		return nil, nil
+	})
+	if executed {
+		cacheRefreshesTotal.WithLabelValues(refreshExecuted).Inc()
+	} else {
+		cacheRefreshesTotal.WithLabelValues(refreshShared).Inc()
+	}
+	return true
+}
+
+// isUnknown returns `true` if the cluster with the given name was still missing after a recent refresh
+func (c *ClusterCache) isUnknown(name string) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	missingSince, found := c.unknownClusters[name]
+	if found && time.Since(missingSince) < c.negativeCacheTTL {
+		cacheRefreshesTotal.WithLabelValues(refreshThrottled).Inc()
+		return true
+	}
+	return false
+}
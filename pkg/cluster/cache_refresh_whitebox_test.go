@@ -0,0 +1,202 @@
+package cluster
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentRefreshesAreCollapsed(t *testing.T) {
+	// given
+	cache := NewClusterCache()
+	member := newTestCachedToolchainCluster(t, "member", Member, ready)
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	cache.setRefreshCache(func() {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		cache.addCachedToolchainCluster(member)
+	})
+	executed := testutil.ToFloat64(cacheRefreshesTotal.WithLabelValues(refreshExecuted))
+	shared := testutil.ToFloat64(cacheRefreshesTotal.WithLabelValues(refreshShared))
+
+	// when
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cluster, ok := cache.GetCachedToolchainCluster("member")
+			assert.True(t, ok)
+			assert.Equal(t, member, cluster)
+		}()
+	}
+	<-started
+	// let the other goroutines wait for the ongoing refresh
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// then
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, executed+1, testutil.ToFloat64(cacheRefreshesTotal.WithLabelValues(refreshExecuted)))
+	assert.Greater(t, testutil.ToFloat64(cacheRefreshesTotal.WithLabelValues(refreshShared)), shared)
+}
+
+func TestMinRefreshInterval(t *testing.T) {
+	// given
+	newCache := func(options ...ClusterCacheOption) (*ClusterCache, *int) {
+		cache := NewClusterCache(options...)
+		calls := 0
+		cache.setRefreshCache(func() {
+			calls++
+		})
+		return cache, &calls
+	}
+
+	t.Run("refresh is throttled", func(t *testing.T) {
+		// given
+		cache, calls := newCache()
+		throttled := testutil.ToFloat64(cacheRefreshesTotal.WithLabelValues(refreshThrottled))
+
+		// when
+		cache.GetMemberClusters()
+		cache.GetMemberClusters()
+		_, ok := cache.GetHostCluster()
+
+		// then
+		assert.False(t, ok)
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, throttled+2, testutil.ToFloat64(cacheRefreshesTotal.WithLabelValues(refreshThrottled)))
+	})
+
+	t.Run("refresh is not throttled after the interval", func(t *testing.T) {
+		// given
+		cache, calls := newCache(MinRefreshInterval(10 * time.Millisecond))
+
+		// when
+		cache.GetMemberClusters()
+		time.Sleep(20 * time.Millisecond)
+		cache.GetMemberClusters()
+
+		// then
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("refresh is not throttled without interval", func(t *testing.T) {
+		// given
+		cache, calls := newCache(MinRefreshInterval(0))
+
+		// when
+		cache.GetMemberClusters()
+		cache.GetMemberClusters()
+
+		// then
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("new refresh func is not throttled", func(t *testing.T) {
+		// given
+		cache, calls := newCache()
+		cache.GetMemberClusters()
+
+		// when
+		cache.setRefreshCache(func() {
+			*calls++
+		})
+		cache.GetMemberClusters()
+
+		// then
+		assert.Equal(t, 2, *calls)
+	})
+}
+
+func TestNegativeCache(t *testing.T) {
+	// given
+	newCache := func(options ...ClusterCacheOption) (*ClusterCache, *int) {
+		cache := NewClusterCache(append([]ClusterCacheOption{MinRefreshInterval(0)}, options...)...)
+		calls := 0
+		cache.setRefreshCache(func() {
+			calls++
+		})
+		return cache, &calls
+	}
+
+	t.Run("unknown cluster does not trigger a new refresh", func(t *testing.T) {
+		// given
+		cache, calls := newCache()
+
+		// when
+		_, ok1 := cache.GetCachedToolchainCluster("unknown")
+		_, ok2 := cache.GetCachedToolchainCluster("unknown")
+
+		// then
+		assert.False(t, ok1)
+		assert.False(t, ok2)
+		assert.Equal(t, 1, *calls)
+
+		t.Run("other unknown cluster triggers a refresh", func(t *testing.T) {
+			// when
+			_, ok := cache.GetCachedToolchainCluster("other")
+
+			// then
+			assert.False(t, ok)
+			assert.Equal(t, 2, *calls)
+		})
+
+		t.Run("unknown cluster is found once added", func(t *testing.T) {
+			// given
+			unknown := newTestCachedToolchainCluster(t, "unknown", Member, ready)
+			cache.addCachedToolchainCluster(unknown)
+			cache.deleteCachedToolchainCluster("unknown")
+
+			// when
+			_, ok := cache.GetCachedToolchainCluster("unknown")
+
+			// then
+			assert.False(t, ok)
+			assert.Equal(t, 3, *calls) // the name is not in the negative cache anymore
+		})
+	})
+
+	t.Run("unknown cluster triggers a new refresh after the TTL", func(t *testing.T) {
+		// given
+		cache, calls := newCache(NegativeCacheTTL(10 * time.Millisecond))
+		_, ok := cache.GetCachedToolchainCluster("unknown")
+		require.False(t, ok)
+
+		// when
+		time.Sleep(20 * time.Millisecond)
+		_, ok = cache.GetCachedToolchainCluster("unknown")
+
+		// then
+		assert.False(t, ok)
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("throttled refresh does not mark the cluster as unknown", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		calls := 0
+		cache.setRefreshCache(func() {
+			calls++
+		})
+		cache.GetMemberClusters()
+
+		// when
+		_, ok := cache.GetCachedToolchainCluster("unknown")
+
+		// then
+		assert.False(t, ok)
+		assert.Equal(t, 1, calls)
+		assert.NotContains(t, cache.unknownClusters, "unknown")
+	})
+}
@@ -3,6 +3,7 @@ package cluster
 import (
 	"sync"
 	"testing"
+	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
@@ -399,6 +400,9 @@ func TestMultipleActionsInParallel(t *testing.T) {
 
 	memberCluster := newTestCachedToolchainCluster(t, "memberCluster", Member, ready)
 	hostCluster := newTestCachedToolchainCluster(t, "hostCluster", Host, ready)
+	// no throttling, so that the cache is refreshed on every miss
+	clusterCache.minRefreshInterval = 0
+	clusterCache.negativeCacheTTL = 0
 	clusterCache.refreshCache = func() {
 		clusterCache.addCachedToolchainCluster(memberCluster)
 		clusterCache.addCachedToolchainCluster(hostCluster)
@@ -455,6 +459,59 @@ func TestMultipleActionsInParallel(t *testing.T) {
 	assert.Equal(t, hostCluster, host)
 }
 
+func TestIsolatedClusterCaches(t *testing.T) {
+	// given
+	defer resetClusterCache()
+	cache1 := NewClusterCache()
+	cache2 := NewClusterCache()
+	host := newTestCachedToolchainCluster(t, "host-cluster", Host, ready)
+	member1 := newTestCachedToolchainCluster(t, "member-1", Member, ready)
+	member2 := newTestCachedToolchainCluster(t, "member-2", Member, ready)
+	cache1.addCachedToolchainCluster(host)
+	cache1.addCachedToolchainCluster(member1)
+	cache2.addCachedToolchainCluster(member2)
+	refreshed := false
+	cache2.setRefreshCache(func() {
+		refreshed = true
+	})
+
+	t.Run("clusters are only available in their own cache", func(t *testing.T) {
+		// when
+		cluster1, ok1 := cache1.GetCachedToolchainCluster("member-1")
+		cluster2, ok2 := cache2.GetCachedToolchainCluster("member-1")
+
+		// then
+		assert.True(t, ok1)
+		assert.Equal(t, member1, cluster1)
+		assert.False(t, ok2)
+		assert.Nil(t, cluster2)
+		assert.True(t, refreshed)
+	})
+
+	t.Run("host and member clusters", func(t *testing.T) {
+		// when
+		host1, ok1 := cache1.GetHostCluster()
+		_, ok2 := cache2.GetHostCluster()
+
+		// then
+		assert.True(t, ok1)
+		assert.Equal(t, host, host1)
+		assert.False(t, ok2)
+		assert.Equal(t, []*CachedToolchainCluster{member1}, cache1.GetMemberClusters())
+		assert.Equal(t, []*CachedToolchainCluster{member2}, cache2.GetMemberClusters(Ready))
+	})
+
+	t.Run("default cache is not affected", func(t *testing.T) {
+		// when
+		_, ok := GetHostCluster()
+
+		// then
+		assert.False(t, ok)
+		assert.Empty(t, GetMemberClusters())
+		assert.Same(t, clusterCache, DefaultClusterCache())
+	})
+}
+
 // clusterOption an option to configure the cluster to use in the tests
 type clusterOption func(*CachedToolchainCluster)
 
@@ -492,5 +549,12 @@ func newTestCachedToolchainCluster(t *testing.T, name string, clusterType Type,
 }
 
 func resetClusterCache() {
-	clusterCache = toolchainClusterClients{clusters: map[string]*CachedToolchainCluster{}}
+	clusterCache.lock.Lock()
+	defer clusterCache.lock.Unlock()
+	clusterCache.clusters = map[string]*CachedToolchainCluster{}
+	clusterCache.refreshCache = nil
+	clusterCache.lastRefresh = time.Time{}
+	clusterCache.minRefreshInterval = DefaultMinRefreshInterval
+	clusterCache.unknownClusters = map[string]time.Time{}
+	clusterCache.negativeCacheTTL = DefaultNegativeCacheTTL
 }
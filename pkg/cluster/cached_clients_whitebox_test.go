@@ -0,0 +1,269 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	crcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	crcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fakeCluster a controller-runtime cluster which only records when it is started and stopped
+type fakeCluster struct {
+	crcluster.Cluster
+	config  *rest.Config
+	scheme  *runtime.Scheme
+	cache   *fakeCache
+	started chan struct{}
+	stopped chan struct{}
+}
+
+// fakeCache a cache which is synced once the cluster is started, unless it is marked as never synced
+type fakeCache struct {
+	*informertest.FakeInformers
+	started  chan struct{}
+	unsynced bool
+}
+
+func (c *fakeCache) WaitForCacheSync(ctx context.Context) bool {
+	select {
+	case <-c.started:
+		return !c.unsynced
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *fakeCluster) GetCache() crcache.Cache {
+	return c.cache
+}
+
+func (c *fakeCluster) Start(ctx context.Context) error {
+	close(c.started)
+	<-ctx.Done()
+	close(c.stopped)
+	return nil
+}
+
+type fakeClusters struct {
+	sync.Mutex
+	clusters []*fakeCluster
+	unsynced bool
+}
+
+func (f *fakeClusters) newCluster(config *rest.Config, opts ...crcluster.Option) (crcluster.Cluster, error) {
+	f.Lock()
+	defer f.Unlock()
+	options := crcluster.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	started := make(chan struct{})
+	c := &fakeCluster{
+		config: config,
+		scheme: options.Scheme,
+		cache: &fakeCache{
+			FakeInformers: &informertest.FakeInformers{},
+			started:       started,
+			unsynced:      f.unsynced,
+		},
+		started: started,
+		stopped: make(chan struct{}),
+	}
+	f.clusters = append(f.clusters, c)
+	return c, nil
+}
+
+func (f *fakeClusters) get(i int) *fakeCluster {
+	f.Lock()
+	defer f.Unlock()
+	return f.clusters[i]
+}
+
+func TestCachedClients(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ToolchainClusterReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster("east", "secret", status, map[string]string{"type": string(Member)})
+	cl := test.NewFakeClient(t, toolchainCluster, sec)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clusters := &fakeClusters{}
+	clustersCache := NewClusterCache()
+	service := NewToolchainClusterServiceWithCachedClients(ctx, clustersCache, cl, logf.Log, "test-namespace", 3*time.Second, newTestClient, clusters.newCluster)
+
+	// when
+	err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, clusters.clusters, 1)
+	first := clusters.get(0)
+	assertClosed(t, first.started) // the cache was synced before the cluster was added
+	cachedCluster, ok := clustersCache.GetCachedToolchainCluster("east")
+	require.True(t, ok)
+	assert.Same(t, first.cache, cachedCluster.CachedReader)
+	assert.NotNil(t, cachedCluster.Client)
+	assert.NotSame(t, cachedCluster.Client, cachedCluster.CachedReader)
+	assert.Equal(t, "mycooltoken", first.config.BearerToken)
+	assert.True(t, first.scheme.Recognizes(toolchainv1alpha1.GroupVersion.WithKind("ToolchainCluster")))
+
+	t.Run("cluster is reused when the config is unchanged", func(t *testing.T) {
+		// when
+		err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.Len(t, clusters.clusters, 1)
+		assertNotStopped(t, first)
+	})
+
+	t.Run("cluster is replaced when the config changes", func(t *testing.T) {
+		// given
+		secret := &corev1.Secret{}
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName("test-namespace", "secret"), secret))
+		secret.Data["token"] = []byte("rotated-token")
+		require.NoError(t, cl.Update(context.TODO(), secret))
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, clusters.clusters, 2)
+		waitFor(t, first.stopped)
+		second := clusters.get(1)
+		waitFor(t, second.started)
+		assertNotStopped(t, second)
+		assert.Equal(t, "rotated-token", second.config.BearerToken)
+		cachedCluster, ok := clustersCache.GetCachedToolchainCluster("east")
+		require.True(t, ok)
+		assert.Same(t, second.cache, cachedCluster.CachedReader)
+	})
+
+	t.Run("cluster is stopped when the ToolchainCluster is deleted", func(t *testing.T) {
+		// when
+		service.DeleteToolchainCluster("east")
+
+		// then
+		waitFor(t, clusters.get(1).stopped)
+	})
+
+	t.Run("clusters are stopped when the context is done", func(t *testing.T) {
+		// given
+		require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+		require.Len(t, clusters.clusters, 3)
+		third := clusters.get(2)
+		waitFor(t, third.started)
+
+		// when
+		cancel()
+
+		// then
+		waitFor(t, third.stopped)
+		assert.Eventually(t, func() bool {
+			_, ok := clustersCache.getCachedToolchainCluster("east", false)
+			return !ok
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestCachedClientsNotSynced(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ToolchainClusterReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster("east", "secret", status, map[string]string{"type": string(Member)})
+	cl := test.NewFakeClient(t, toolchainCluster, sec)
+	clusters := &fakeClusters{unsynced: true}
+	clustersCache := NewClusterCache()
+	service := NewToolchainClusterServiceWithCachedClients(context.TODO(), clustersCache, cl, logf.Log, "test-namespace", 3*time.Second, newTestClient, clusters.newCluster)
+
+	// when
+	err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+	// then
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot create ToolchainCluster cached client: the cache of cluster east was not synced")
+	require.Len(t, clusters.clusters, 1)
+	waitFor(t, clusters.get(0).stopped)
+	_, ok := clustersCache.getCachedToolchainCluster("east", false)
+	assert.False(t, ok)
+}
+
+func TestCachedClientsFailure(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ToolchainClusterReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster("east", "secret", status, map[string]string{"type": string(Member)})
+	cl := test.NewFakeClient(t, toolchainCluster, sec)
+	clustersCache := NewClusterCache()
+	service := NewToolchainClusterServiceWithCachedClients(context.TODO(), clustersCache, cl, logf.Log, "test-namespace", 3*time.Second, newTestClient,
+		func(config *rest.Config, opts ...crcluster.Option) (crcluster.Cluster, error) {
+			return nil, errors.New("some error")
+		})
+
+	// when
+	err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+	// then
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot create ToolchainCluster cached client: some error")
+	_, ok := clustersCache.getCachedToolchainCluster("east", false)
+	assert.False(t, ok)
+}
+
+func TestDirectClientsByDefault(t *testing.T) {
+	// given
+	defer gock.Off()
+	defer resetClusterCache()
+	status := test.NewClusterStatus(toolchainv1alpha1.ToolchainClusterReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster("east", "secret", status, map[string]string{"type": string(Member)})
+	service := newToolchainClusterService(test.NewFakeClient(t, toolchainCluster, sec), 3*time.Second)
+
+	// when
+	err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+	// then
+	require.NoError(t, err)
+	cachedCluster, ok := GetCachedToolchainCluster("east")
+	require.True(t, ok)
+	assert.Same(t, cachedCluster.Client, cachedCluster.CachedReader)
+	assert.Nil(t, cachedCluster.cluster)
+}
+
+func waitFor(t *testing.T, done chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timeout")
+	}
+}
+
+func assertClosed(t *testing.T, done chan struct{}) {
+	select {
+	case <-done:
+	default:
+		assert.Fail(t, "the channel should be closed")
+	}
+}
+
+func assertNotStopped(t *testing.T, c *fakeCluster) {
+	select {
+	case <-c.stopped:
+		assert.Fail(t, "the cluster should not be stopped")
+	default:
+	}
+}
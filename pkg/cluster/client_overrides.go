@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+// The annotations of a ToolchainCluster to override the configuration of the client of the cluster
+const (
+	// ClientQPSAnnotation the maximum queries per second to the cluster (default: 20)
+	ClientQPSAnnotation = toolchainv1alpha1.LabelKeyPrefix + "client-qps"
+	// ClientBurstAnnotation the maximum burst of queries to the cluster (default: 30)
+	ClientBurstAnnotation = toolchainv1alpha1.LabelKeyPrefix + "client-burst"
+	// ClientTimeoutAnnotation the timeout of the requests to the cluster, eg, `10s` (default: the timeout of the ToolchainClusterService)
+	ClientTimeoutAnnotation = toolchainv1alpha1.LabelKeyPrefix + "client-timeout"
+	// ClientProxyURLAnnotation the URL of the HTTP(S) or SOCKS5 proxy to use to connect to the cluster (default: the proxy of the environment)
+	ClientProxyURLAnnotation = toolchainv1alpha1.LabelKeyPrefix + "client-proxy-url"
+	// ClientTLSServerNameAnnotation the server name used to verify the certificate of the cluster (default: the host of the API endpoint)
+	ClientTLSServerNameAnnotation = toolchainv1alpha1.LabelKeyPrefix + "client-tls-server-name"
+	// ClientUserAgentSuffixAnnotation the suffix appended to the default user agent of the client
+	ClientUserAgentSuffixAnnotation = toolchainv1alpha1.LabelKeyPrefix + "client-user-agent-suffix"
+)
+
+// applyClientOverrides applies the overrides of the client configuration defined in the annotations of the given ToolchainCluster.
+func applyClientOverrides(restConfig *rest.Config, toolchainCluster *toolchainv1alpha1.ToolchainCluster) error {
+	annotations := toolchainCluster.Annotations
+	invalid := func(err error, annotation string) error {
+		return errors.Wrapf(err, "invalid value %q of the annotation %s of cluster %s", annotations[annotation], annotation, toolchainCluster.Name)
+	}
+	if value, found := annotations[ClientQPSAnnotation]; found {
+		qps, err := strconv.ParseFloat(value, 32)
+		if err == nil && qps <= 0 {
+			err = errors.New("the QPS must be positive")
+		}
+		if err != nil {
+			return invalid(err, ClientQPSAnnotation)
+		}
+		restConfig.QPS = float32(qps)
+	}
+	if value, found := annotations[ClientBurstAnnotation]; found {
+		burst, err := strconv.Atoi(value)
+		if err == nil && burst <= 0 {
+			err = errors.New("the burst must be positive")
+		}
+		if err != nil {
+			return invalid(err, ClientBurstAnnotation)
+		}
+		restConfig.Burst = burst
+	}
+	if value, found := annotations[ClientTimeoutAnnotation]; found {
+		timeout, err := time.ParseDuration(value)
+		if err == nil && timeout <= 0 {
+			err = errors.New("the timeout must be positive")
+		}
+		if err != nil {
+			return invalid(err, ClientTimeoutAnnotation)
+		}
+		restConfig.Timeout = timeout
+	}
+	if value, found := annotations[ClientProxyURLAnnotation]; found {
+		u, err := url.Parse(value)
+		if err == nil && (u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5")) {
+			err = errors.New("the proxy URL must have a host and the http, https or socks5 scheme")
+		}
+		if err != nil {
+			return invalid(err, ClientProxyURLAnnotation)
+		}
+		restConfig.Proxy = http.ProxyURL(u)
+	}
+	if value, found := annotations[ClientTLSServerNameAnnotation]; found {
+		if strings.TrimSpace(value) == "" || strings.ContainsAny(value, " /:") {
+			return invalid(errors.New("the TLS server name must be a host name"), ClientTLSServerNameAnnotation)
+		}
+		restConfig.ServerName = value
+	}
+	if value, found := annotations[ClientUserAgentSuffixAnnotation]; found {
+		if strings.TrimSpace(value) == "" || strings.ContainsAny(value, "\r\n") {
+			return invalid(errors.New("the user agent suffix must be a non-empty single line"), ClientUserAgentSuffixAnnotation)
+		}
+		userAgent := restConfig.UserAgent
+		if userAgent == "" {
+			userAgent = rest.DefaultKubernetesUserAgent()
+		}
+		restConfig.UserAgent = userAgent + " " + value
+	}
+	return nil
+}
+
+// effectiveProxyURL returns the URL of the proxy used by the given rest config to connect to its host, if any,
+// regardless of where the proxy was defined (ie, in the kubeconfig or with the `ClientProxyURLAnnotation`)
+func effectiveProxyURL(restConfig *rest.Config) (string, error) {
+	if restConfig.Proxy == nil {
+		return "", nil
+	}
+	req, err := http.NewRequest(http.MethodGet, restConfig.Host, nil)
+	if err != nil {
+		return "", err
+	}
+	u, err := restConfig.Proxy(req)
+	if err != nil || u == nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// restConfigChanged returns `true` if the rest configs of the given cluster configs are different.
+// The proxy funcs cannot be compared, hence the proxy URLs are compared instead.
+func restConfigChanged(previous, current *Config) bool {
+	if previous.ProxyURL != current.ProxyURL {
+		return true
+	}
+	previousRestConfig := rest.CopyConfig(previous.RestConfig)
+	previousRestConfig.Proxy = nil
+	currentRestConfig := rest.CopyConfig(current.RestConfig)
+	currentRestConfig.Proxy = nil
+	return !reflect.DeepEqual(previousRestConfig, currentRestConfig)
+}
@@ -0,0 +1,92 @@
+package cluster_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test/verify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestNewClusterConfigWithClientOverrides(t *testing.T) {
+	status := test.NewClusterStatus(toolchainv1alpha1.ToolchainClusterReady, corev1.ConditionTrue)
+	newClusterConfig := func(t *testing.T, annotations map[string]string) (*cluster.Config, error) {
+		toolchainCluster, secret := test.NewToolchainCluster("east", "secret", status, verify.Labels(cluster.Member, "", test.NameHost))
+		toolchainCluster.Annotations = annotations
+		cl := test.NewFakeClient(t, toolchainCluster, secret)
+		return cluster.NewClusterConfig(cl, toolchainCluster, 3*time.Second)
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		// when
+		config, err := newClusterConfig(t, nil)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, float32(20), config.RestConfig.QPS)
+		assert.Equal(t, 30, config.RestConfig.Burst)
+		assert.Equal(t, 3*time.Second, config.RestConfig.Timeout)
+		assert.Nil(t, config.RestConfig.Proxy)
+		assert.Empty(t, config.ProxyURL)
+		assert.Empty(t, config.RestConfig.ServerName)
+		assert.Empty(t, config.RestConfig.UserAgent)
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		// when
+		config, err := newClusterConfig(t, map[string]string{
+			cluster.ClientQPSAnnotation:             "50.5",
+			cluster.ClientBurstAnnotation:           "100",
+			cluster.ClientTimeoutAnnotation:         "1m",
+			cluster.ClientProxyURLAnnotation:        "http://proxy.com:3128",
+			cluster.ClientTLSServerNameAnnotation:   "api.member.com",
+			cluster.ClientUserAgentSuffixAnnotation: "member-1",
+		})
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, float32(50.5), config.RestConfig.QPS)
+		assert.Equal(t, 100, config.RestConfig.Burst)
+		assert.Equal(t, time.Minute, config.RestConfig.Timeout)
+		assert.Equal(t, "http://proxy.com:3128", config.ProxyURL)
+		require.NotNil(t, config.RestConfig.Proxy)
+		req, err := http.NewRequest(http.MethodGet, "http://cluster.com/api", nil)
+		require.NoError(t, err)
+		proxy, err := config.RestConfig.Proxy(req)
+		require.NoError(t, err)
+		assert.Equal(t, "http://proxy.com:3128", proxy.String())
+		assert.Equal(t, "api.member.com", config.RestConfig.ServerName)
+		assert.True(t, strings.HasPrefix(config.RestConfig.UserAgent, rest.DefaultKubernetesUserAgent()))
+		assert.True(t, strings.HasSuffix(config.RestConfig.UserAgent, " member-1"))
+	})
+
+	t.Run("invalid overrides", func(t *testing.T) {
+		for annotation, values := range map[string][]string{
+			cluster.ClientQPSAnnotation:             {"fast", "0", "-1"},
+			cluster.ClientBurstAnnotation:           {"1.5", "0"},
+			cluster.ClientTimeoutAnnotation:         {"10", "-1s"},
+			cluster.ClientProxyURLAnnotation:        {"proxy.com:3128", "ftp://proxy.com", "http://"},
+			cluster.ClientTLSServerNameAnnotation:   {"", "https://api.member.com"},
+			cluster.ClientUserAgentSuffixAnnotation: {" ", "member\r\n1"},
+		} {
+			for _, value := range values {
+				t.Run(annotation+"="+value, func(t *testing.T) {
+					// when
+					_, err := newClusterConfig(t, map[string]string{annotation: value})
+
+					// then
+					require.Error(t, err)
+					assert.Contains(t, err.Error(), "of the annotation "+annotation+" of cluster east")
+				})
+			}
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// The keys of the credentials in the Secret referenced by a ToolchainCluster
+const (
+	// ToolchainTokenKey the key of the bearer token
+	ToolchainTokenKey = "token"
+	// ToolchainClientCertificateKey the key of the PEM-encoded client certificate (along with the `tls.key` client key)
+	ToolchainClientCertificateKey = v1.TLSCertKey
+	// ToolchainClientKeyKey the key of the PEM-encoded client key (along with the `tls.crt` client certificate)
+	ToolchainClientKeyKey = v1.TLSPrivateKeyKey
+	// ToolchainKubeconfigKey the key of a kubeconfig, eg, with exec-based credentials. The kubeconfig cannot reference any file
+	// (only the inline `*-data` fields and the `token` are supported). Note that the command of the exec-based credentials runs
+	// in the pod of the operator, so the write access to the secrets of the ToolchainClusters must be restricted to the cluster admins.
+	ToolchainKubeconfigKey = "kubeconfig"
+	// ToolchainKubeconfigContextKey the key of the (optional) context to use in the kubeconfig (default: the current context of the kubeconfig)
+	ToolchainKubeconfigContextKey = "context"
+)
+
+// newRestConfigFromSecret returns the config to connect to the API endpoint of the cluster with the credentials of the given Secret:
+// - if the Secret has a kubeconfig, then the config is built from the kubeconfig (and the optional context), except for the server,
+// which is always the API endpoint of the ToolchainCluster. A kubeconfig which references files or an auth provider is rejected,
+// since the files would be read from the filesystem of the operator (eg, its own ServiceAccount token).
+// - otherwise, the config has the bearer token and/or the client certificate and key of the Secret.
+func newRestConfigFromSecret(clusterName, apiEndpoint string, secret *v1.Secret) (*rest.Config, error) {
+	if kubeconfig := secret.Data[ToolchainKubeconfigKey]; len(kubeconfig) > 0 {
+		return newRestConfigFromKubeconfig(clusterName, apiEndpoint, kubeconfig, string(secret.Data[ToolchainKubeconfigContextKey]))
+	}
+
+	restConfig, err := clientcmd.BuildConfigFromFlags(apiEndpoint, "")
+	if err != nil {
+		return nil, err
+	}
+	token := secret.Data[ToolchainTokenKey]
+	cert := secret.Data[ToolchainClientCertificateKey]
+	key := secret.Data[ToolchainClientKeyKey]
+	if (len(cert) == 0) != (len(key) == 0) {
+		return nil, errors.Errorf("the secret for cluster %s must have non-empty values for both %q and %q", clusterName, ToolchainClientCertificateKey, ToolchainClientKeyKey)
+	}
+	if len(token) == 0 && len(cert) == 0 {
+		return nil, errors.Errorf("the secret for cluster %s is missing a non-empty value for %q (or for %q, or for %q and %q)",
+			clusterName, ToolchainTokenKey, ToolchainKubeconfigKey, ToolchainClientCertificateKey, ToolchainClientKeyKey)
+	}
+	restConfig.BearerToken = string(token)
+	restConfig.CertData = cert
+	restConfig.KeyData = key
+	return restConfig, nil
+}
+
+func newRestConfigFromKubeconfig(clusterName, apiEndpoint string, kubeconfig []byte, context string) (*rest.Config, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "the secret for cluster %s has an invalid kubeconfig", clusterName)
+	}
+	if err := verifyNoFileReference(config); err != nil {
+		return nil, errors.Wrapf(err, "the secret for cluster %s has an invalid kubeconfig", clusterName)
+	}
+	if context != "" {
+		if _, found := config.Contexts[context]; !found {
+			return nil, errors.Errorf("the kubeconfig for cluster %s has no context named %q", clusterName, context)
+		}
+	}
+	restConfig, err := clientcmd.NewNonInteractiveClientConfig(*config, context, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		return nil, errors.Wrapf(err, "the secret for cluster %s has an invalid kubeconfig", clusterName)
+	}
+	// the `spec.apiEndpoint` of the ToolchainCluster is the source of truth for the server, regardless of the kubeconfig
+	restConfig.Host = apiEndpoint
+	return restConfig, nil
+}
+
+// verifyNoFileReference returns an error if any of the clusters or users of the given kubeconfig references a file
+// or an auth provider, so that only the inline credentials (and the exec-based credentials) are used
+func verifyNoFileReference(config *clientcmdapi.Config) error {
+	for name, cluster := range config.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return errors.Errorf("the cluster %q references the file %q, use the `certificate-authority-data` field instead", name, cluster.CertificateAuthority)
+		}
+	}
+	for name, user := range config.AuthInfos {
+		for _, path := range []string{user.ClientCertificate, user.ClientKey, user.TokenFile} {
+			if path != "" {
+				return errors.Errorf("the user %q references the file %q, use the inline credentials instead", name, path)
+			}
+		}
+		if user.AuthProvider != nil {
+			return errors.Errorf("the user %q uses the auth provider %q which is not supported", name, user.AuthProvider.Name)
+		}
+	}
+	return nil
+}
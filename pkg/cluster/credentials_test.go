@@ -0,0 +1,247 @@
+package cluster_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test/verify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const kubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: member
+  cluster:
+    server: https://ignored.com
+    certificate-authority-data: ` + "ZHVtbXk=" + `
+users:
+- name: token-user
+  user:
+    token: kubeconfig-token
+- name: exec-user
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: get-token
+      args: ["--cluster", "member"]
+contexts:
+- name: token
+  context:
+    cluster: member
+    user: token-user
+- name: exec
+  context:
+    cluster: member
+    user: exec-user
+current-context: token
+`
+
+func TestNewClusterConfigWithCredentials(t *testing.T) {
+	status := test.NewClusterStatus(toolchainv1alpha1.ToolchainClusterReady, corev1.ConditionTrue)
+	newClusterConfig := func(t *testing.T, data map[string]string, caBundle string) (*cluster.Config, error) {
+		toolchainCluster, secret := test.NewToolchainCluster("east", "secret", status, verify.Labels(cluster.Member, "", test.NameHost))
+		toolchainCluster.Spec.CABundle = caBundle
+		secret.Data = map[string][]byte{}
+		for k, v := range data {
+			secret.Data[k] = []byte(v)
+		}
+		cl := test.NewFakeClient(t, toolchainCluster, secret)
+		return cluster.NewClusterConfig(cl, toolchainCluster, time.Second)
+	}
+
+	t.Run("token", func(t *testing.T) {
+		// when
+		config, err := newClusterConfig(t, map[string]string{"token": "mycooltoken"}, "")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "http://cluster.com", config.RestConfig.Host)
+		assert.Equal(t, "mycooltoken", config.RestConfig.BearerToken)
+		assert.Empty(t, config.RestConfig.CertData)
+	})
+
+	t.Run("client certificate", func(t *testing.T) {
+		// when
+		config, err := newClusterConfig(t, map[string]string{"tls.crt": "cert", "tls.key": "key"}, "ZHVtbXk=")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "http://cluster.com", config.RestConfig.Host)
+		assert.Empty(t, config.RestConfig.BearerToken)
+		assert.Equal(t, []byte("cert"), config.RestConfig.CertData)
+		assert.Equal(t, []byte("key"), config.RestConfig.KeyData)
+		assert.Equal(t, []byte("dummy"), config.RestConfig.CAData)
+	})
+
+	t.Run("kubeconfig", func(t *testing.T) {
+		t.Run("current context", func(t *testing.T) {
+			// when
+			config, err := newClusterConfig(t, map[string]string{"kubeconfig": kubeconfig}, "")
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "http://cluster.com", config.RestConfig.Host)
+			assert.Equal(t, "kubeconfig-token", config.RestConfig.BearerToken)
+			assert.Equal(t, []byte("dummy"), config.RestConfig.CAData)
+			assert.False(t, config.RestConfig.Insecure)
+			assert.Equal(t, toolchainAPIDefaults{QPS: 20, Burst: 30, Timeout: time.Second}, restDefaults(config))
+		})
+
+		t.Run("selected context with exec credentials", func(t *testing.T) {
+			// when
+			config, err := newClusterConfig(t, map[string]string{"kubeconfig": kubeconfig, "context": "exec"}, "")
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, config.RestConfig.BearerToken)
+			require.NotNil(t, config.RestConfig.ExecProvider)
+			assert.Equal(t, "get-token", config.RestConfig.ExecProvider.Command)
+			assert.Equal(t, []string{"--cluster", "member"}, config.RestConfig.ExecProvider.Args)
+		})
+
+		t.Run("CA bundle of the ToolchainCluster takes precedence", func(t *testing.T) {
+			// when
+			config, err := newClusterConfig(t, map[string]string{"kubeconfig": kubeconfig}, base64.StdEncoding.EncodeToString([]byte("other")))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []byte("other"), config.RestConfig.CAData)
+		})
+
+		t.Run("kubeconfig takes precedence over token", func(t *testing.T) {
+			// when
+			config, err := newClusterConfig(t, map[string]string{"kubeconfig": kubeconfig, "token": "mycooltoken"}, "")
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "kubeconfig-token", config.RestConfig.BearerToken)
+		})
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			data        map[string]string
+			expectedErr string
+		}{
+			"no credentials": {
+				data:        map[string]string{"other": "value"},
+				expectedErr: `the secret for cluster east is missing a non-empty value for "token" (or for "kubeconfig", or for "tls.crt" and "tls.key")`,
+			},
+			"client certificate without key": {
+				data:        map[string]string{"tls.crt": "cert"},
+				expectedErr: `the secret for cluster east must have non-empty values for both "tls.crt" and "tls.key"`,
+			},
+			"client key without certificate": {
+				data:        map[string]string{"token": "mycooltoken", "tls.key": "key"},
+				expectedErr: `the secret for cluster east must have non-empty values for both "tls.crt" and "tls.key"`,
+			},
+			"unknown context": {
+				data:        map[string]string{"kubeconfig": kubeconfig, "context": "unknown"},
+				expectedErr: `the kubeconfig for cluster east has no context named "unknown"`,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				// when
+				_, err := newClusterConfig(t, tc.data, "")
+
+				// then
+				require.EqualError(t, err, tc.expectedErr)
+			})
+		}
+
+		t.Run("kubeconfig referencing files", func(t *testing.T) {
+			for name, tc := range map[string]struct {
+				cluster     string
+				user        string
+				expectedErr string
+			}{
+				"certificate authority": {
+					cluster:     "certificate-authority: /etc/ssl/certs/ca.crt",
+					user:        "token: kubeconfig-token",
+					expectedErr: `the cluster "member" references the file "/etc/ssl/certs/ca.crt", use the ` + "`certificate-authority-data`" + ` field instead`,
+				},
+				"client certificate": {
+					cluster:     "certificate-authority-data: ZHVtbXk=",
+					user:        "client-certificate: /etc/tls/tls.crt\n    client-key-data: a2V5",
+					expectedErr: `the user "member-user" references the file "/etc/tls/tls.crt", use the inline credentials instead`,
+				},
+				"client key": {
+					cluster:     "certificate-authority-data: ZHVtbXk=",
+					user:        "client-certificate-data: Y2VydA==\n    client-key: /etc/tls/tls.key",
+					expectedErr: `the user "member-user" references the file "/etc/tls/tls.key", use the inline credentials instead`,
+				},
+				"token file": {
+					cluster:     "certificate-authority-data: ZHVtbXk=",
+					user:        "tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token",
+					expectedErr: `the user "member-user" references the file "/var/run/secrets/kubernetes.io/serviceaccount/token", use the inline credentials instead`,
+				},
+				"auth provider": {
+					cluster:     "certificate-authority-data: ZHVtbXk=",
+					user:        "auth-provider:\n      name: gcp",
+					expectedErr: `the user "member-user" uses the auth provider "gcp" which is not supported`,
+				},
+			} {
+				t.Run(name, func(t *testing.T) {
+					// given
+					kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: member
+  cluster:
+    server: https://ignored.com
+    %s
+users:
+- name: member-user
+  user:
+    %s
+contexts:
+- name: member
+  context:
+    cluster: member
+    user: member-user
+current-context: member
+`, tc.cluster, tc.user)
+
+					// when
+					_, err := newClusterConfig(t, map[string]string{"kubeconfig": kubeconfig}, "")
+
+					// then
+					require.Error(t, err)
+					assert.Contains(t, err.Error(), "the secret for cluster east has an invalid kubeconfig")
+					assert.Contains(t, err.Error(), tc.expectedErr)
+				})
+			}
+		})
+
+		t.Run("invalid kubeconfig", func(t *testing.T) {
+			// when
+			_, err := newClusterConfig(t, map[string]string{"kubeconfig": "invalid"}, "")
+
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "the secret for cluster east has an invalid kubeconfig")
+		})
+	})
+}
+
+type toolchainAPIDefaults struct {
+	QPS     float32
+	Burst   int
+	Timeout time.Duration
+}
+
+func restDefaults(config *cluster.Config) toolchainAPIDefaults {
+	return toolchainAPIDefaults{
+		QPS:     config.RestConfig.QPS,
+		Burst:   config.RestConfig.Burst,
+		Timeout: config.RestConfig.Timeout,
+	}
+}
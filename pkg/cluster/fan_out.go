@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	applyclient "github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/pkg/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ClusterApplyReport the outcome of the apply of a set of objects to a single cluster
+type ClusterApplyReport struct {
+	// ClusterName the name of the cluster the objects were applied to
+	ClusterName string
+	// Results the result of each given object, at the same index, including the objects which could not be applied
+	// (see `ApplyResults.Failed` and `ApplyResult.Err`)
+	Results applyclient.ApplyResults
+	// Err the (aggregated) error of the objects which could not be applied, if any
+	Err error
+}
+
+// ApplyToMemberClusters applies the given objects (see `ApplyClient.Apply`) to all the member clusters which match the given conditions
+// (eg, `ApplyToMemberClusters(ctx, objs, labels, cluster.Ready)`). The clusters are processed concurrently.
+// Returns a report for each cluster (sorted by cluster name), as well as an aggregated error of all the clusters on which some objects
+// could not be applied. A failure on a cluster does not prevent the objects from being applied on the other clusters.
+func ApplyToMemberClusters(ctx context.Context, objs []client.Object, newLabels map[string]string, conditions ...Condition) ([]ClusterApplyReport, error) {
+	return ApplyToClusters(ctx, MemberClusters(conditions...), objs, newLabels)
+}
+
+// ApplyToMemberClusters applies the given objects to all the member clusters of this cache which match the given conditions
+// (see the package-level `ApplyToMemberClusters`)
+func (c *ClusterCache) ApplyToMemberClusters(ctx context.Context, objs []client.Object, newLabels map[string]string, conditions ...Condition) ([]ClusterApplyReport, error) {
+	return ApplyToClusters(ctx, c.GetMemberClusters(conditions...), objs, newLabels)
+}
+
+// ApplyToClusters applies the given objects to all the given clusters concurrently (see `ApplyToMemberClusters`).
+// Additional options can be given to configure the ApplyClient used for each cluster
+// (by default, the name of the cluster is set as the `cluster` label of the metrics).
+func ApplyToClusters(ctx context.Context, clusters []*CachedToolchainCluster, objs []client.Object, newLabels map[string]string, options ...applyclient.ApplyClientOption) ([]ClusterApplyReport, error) {
+	reports := make([]ClusterApplyReport, len(clusters))
+	var wg sync.WaitGroup
+	for i, cluster := range clusters {
+		wg.Add(1)
+		go func(i int, cluster *CachedToolchainCluster) {
+			defer wg.Done()
+			// the objects are modified when they are applied, hence each cluster gets its own copy
+			clusterObjs := make([]client.Object, len(objs))
+			for j, obj := range objs {
+				clusterObjs[j] = obj.DeepCopyObject().(client.Object)
+			}
+			clientOptions := append([]applyclient.ApplyClientOption{applyclient.ClusterName(cluster.Name)}, options...)
+			cl := applyclient.NewApplyClient(cluster.Client, clientOptions...)
+			results, err := cl.ApplyCtx(ctx, clusterObjs, newLabels)
+			reports[i] = ClusterApplyReport{
+				ClusterName: cluster.Name,
+				Results:     results,
+				Err:         err,
+			}
+		}(i, cluster)
+	}
+	wg.Wait()
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].ClusterName < reports[j].ClusterName
+	})
+	var errs []error
+	for _, report := range reports {
+		if report.Err != nil {
+			errs = append(errs, errors.Wrapf(report.Err, "unable to apply the objects to the cluster '%s'", report.ClusterName))
+		}
+	}
+	return reports, utilerrors.NewAggregate(errs)
+}
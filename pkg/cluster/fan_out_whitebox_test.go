@@ -0,0 +1,144 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	applyclient "github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyToMemberClusters(t *testing.T) {
+	// given
+	newObjects := func() []client.Object {
+		return []client.Object{
+			&v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "toolchain-member-operator"},
+				Data:       map[string]string{"param": "value"},
+			},
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "toolchain-member-operator"},
+			},
+		}
+	}
+	labels := map[string]string{"toolchain.dev.openshift.com/provider": "codeready-toolchain"}
+
+	t.Run("should apply objects to ready member clusters only", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		member1 := newTestCachedToolchainCluster(t, "member-1", Member, ready)
+		member2 := newTestCachedToolchainCluster(t, "member-2", Member, ready)
+		member3 := newTestCachedToolchainCluster(t, "member-3", Member, notReady)
+		host := newTestCachedToolchainCluster(t, "host", Host, ready)
+		for _, c := range []*CachedToolchainCluster{member2, member1, member3, host} {
+			clusterCache.addCachedToolchainCluster(c)
+		}
+		objs := newObjects()
+
+		// when
+		reports, err := ApplyToMemberClusters(context.TODO(), objs, labels, Ready)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, reports, 2)
+		for i, member := range []*CachedToolchainCluster{member1, member2} {
+			assert.Equal(t, member.Name, reports[i].ClusterName)
+			require.NoError(t, reports[i].Err)
+			require.Len(t, reports[i].Results, 2)
+			assert.Equal(t, applyclient.ApplyCreated, reports[i].Results[0].Operation)
+			assert.Equal(t, applyclient.ApplyCreated, reports[i].Results[1].Operation)
+			cm := &v1.ConfigMap{}
+			require.NoError(t, member.Client.Get(context.TODO(), types.NamespacedName{Namespace: "toolchain-member-operator", Name: "config"}, cm))
+			assert.Equal(t, "codeready-toolchain", cm.Labels["toolchain.dev.openshift.com/provider"])
+		}
+		for _, c := range []*CachedToolchainCluster{member3, host} {
+			cm := &v1.ConfigMap{}
+			err := c.Client.Get(context.TODO(), types.NamespacedName{Namespace: "toolchain-member-operator", Name: "config"}, cm)
+			assert.True(t, apierrors.IsNotFound(err))
+		}
+		// the given objects are not modified
+		assert.Empty(t, objs[0].GetLabels())
+	})
+
+	t.Run("should report partial failures", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		member1 := newTestCachedToolchainCluster(t, "member-1", Member, ready)
+		member2 := newTestCachedToolchainCluster(t, "member-2", Member, ready)
+		member2.Client.(*test.FakeClient).MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*v1.ConfigMap); ok {
+				return fmt.Errorf("unable to create configmap")
+			}
+			return member2.Client.(*test.FakeClient).Client.Create(ctx, obj, opts...)
+		}
+		clusterCache.addCachedToolchainCluster(member1)
+		clusterCache.addCachedToolchainCluster(member2)
+
+		// when
+		reports, err := ApplyToMemberClusters(context.TODO(), newObjects(), labels, Ready)
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to apply the objects to the cluster 'member-2'")
+		assert.Contains(t, err.Error(), "unable to create configmap")
+		assert.NotContains(t, err.Error(), "member-1")
+		require.Len(t, reports, 2)
+		assert.NoError(t, reports[0].Err)
+		assert.Len(t, reports[0].Results, 2)
+		assert.Equal(t, "member-2", reports[1].ClusterName)
+		require.Error(t, reports[1].Err)
+		require.Len(t, reports[1].Results, 2)
+		// the namespace was applied anyway
+		assert.Equal(t, "Namespace", reports[1].Results[1].GVK.Kind)
+		assert.Equal(t, applyclient.ApplyCreated, reports[1].Results[1].Operation)
+		failed := reports[1].Results.Failed()
+		require.Len(t, failed, 1)
+		assert.Equal(t, "ConfigMap", failed[0].GVK.Kind)
+		assert.Equal(t, "config", failed[0].Object.GetName())
+		assert.Contains(t, failed[0].Err.Error(), "unable to create configmap")
+	})
+
+	t.Run("should apply objects to the member clusters of the given cache", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		cache := NewClusterCache()
+		member1 := newTestCachedToolchainCluster(t, "member-1", Member, ready)
+		cache.addCachedToolchainCluster(member1)
+		// the clusters of the default cache are ignored
+		member2 := newTestCachedToolchainCluster(t, "member-2", Member, ready)
+		clusterCache.addCachedToolchainCluster(member2)
+
+		// when
+		reports, err := cache.ApplyToMemberClusters(context.TODO(), newObjects(), labels, Ready)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.Equal(t, "member-1", reports[0].ClusterName)
+		cm := &v1.ConfigMap{}
+		require.NoError(t, member1.Client.Get(context.TODO(), types.NamespacedName{Namespace: "toolchain-member-operator", Name: "config"}, cm))
+		err = member2.Client.Get(context.TODO(), types.NamespacedName{Namespace: "toolchain-member-operator", Name: "config"}, cm)
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("should return no report when no cluster matches", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member-1", Member, notReady))
+
+		// when
+		reports, err := ApplyToMemberClusters(context.TODO(), newObjects(), labels, Ready)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, reports)
+	})
+}
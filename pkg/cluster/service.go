@@ -2,9 +2,7 @@ package cluster
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
-	"reflect"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
@@ -15,8 +13,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
 )
 
 const (
@@ -31,7 +29,6 @@ const (
 
 	toolchainAPIQPS   = 20.0
 	toolchainAPIBurst = 30
-	toolchainTokenKey = "token"
 )
 
 // ToolchainClusterService manages cached cluster kube clients and related ToolchainCluster CRDs
@@ -42,30 +39,70 @@ type ToolchainClusterService struct {
 	namespace string
 	timeout   time.Duration
 	newClient NewClient
+	cache     *ClusterCache
+	// ctx the context of the cached clients (the clusters are stopped when it is done), if the cached clients are enabled
+	ctx        context.Context
+	newCluster NewCluster
 }
 
 type NewClient func(config *rest.Config, options client.Options) (client.Client, error)
 
+// NewCluster the func to create a controller-runtime cluster, eg, `crcluster.New`
+type NewCluster func(config *rest.Config, opts ...crcluster.Option) (crcluster.Cluster, error)
+
 // NewToolchainClusterServiceWithClient creates a new instance of ToolchainClusterService object and assigns the given newClient functione to be used for creating a client
 func NewToolchainClusterServiceWithClient(client client.Client, log logr.Logger, namespace string, timeout time.Duration, newClient NewClient) ToolchainClusterService {
-	service := NewToolchainClusterService(client, log, namespace, timeout)
-	service.newClient = newClient
-	clusterCache.refreshCache = service.refreshCache
-	return service
+	return NewToolchainClusterServiceWithCache(clusterCache, client, log, namespace, timeout, newClient)
 }
 
-// NewToolchainClusterService creates a new instance of ToolchainClusterService object and assigns the refreshCache function to the cache instance
+// NewToolchainClusterService creates a new instance of ToolchainClusterService object which fills the default cache of the clusters,
+// and assigns the refreshCache function to the default cache instance
 func NewToolchainClusterService(client client.Client, log logr.Logger, namespace string, timeout time.Duration) ToolchainClusterService {
+	return NewToolchainClusterServiceWithCache(clusterCache, client, log, namespace, timeout, nil)
+}
+
+// NewToolchainClusterServiceWithCache creates a new instance of ToolchainClusterService object which fills the given cache of the clusters,
+// and assigns the refreshCache function to the given cache instance. If the newClient function is nil, then the clients are created with `client.New`
+func NewToolchainClusterServiceWithCache(cache *ClusterCache, client client.Client, log logr.Logger, namespace string, timeout time.Duration, newClient NewClient) ToolchainClusterService {
 	service := ToolchainClusterService{
 		client:    client,
 		log:       log,
 		namespace: namespace,
 		timeout:   timeout,
+		newClient: newClient,
+		cache:     cache,
+	}
+	cache.setRefreshCache(service.refreshCache)
+	return service
+}
+
+// NewToolchainClusterServiceWithCachedClients creates a new instance of ToolchainClusterService object which fills the given cache of the clusters
+// with cached clients: in addition to the direct client, a controller-runtime cluster (with its own informers) is started for each ToolchainCluster,
+// and its cache is exposed (once synced) as the `CachedReader` of the CachedToolchainCluster. The cluster is stopped when the ToolchainCluster is deleted,
+// when its client is rebuilt, or when the given context is done, in which case the CachedToolchainCluster is removed from the cache. If the newCluster function is nil, then the clusters are created with `crcluster.New`
+func NewToolchainClusterServiceWithCachedClients(ctx context.Context, cache *ClusterCache, client client.Client, log logr.Logger, namespace string, timeout time.Duration, newClient NewClient, newCluster NewCluster) ToolchainClusterService {
+	if newCluster == nil {
+		newCluster = crcluster.New
+	}
+	service := ToolchainClusterService{
+		client:     client,
+		log:        log,
+		namespace:  namespace,
+		timeout:    timeout,
+		newClient:  newClient,
+		cache:      cache,
+		ctx:        ctx,
+		newCluster: newCluster,
 	}
-	clusterCache.refreshCache = service.refreshCache
+	cache.setRefreshCache(service.refreshCache)
 	return service
 }
 
+// Cache returns the cache of the clusters filled by this service
+func (s *ToolchainClusterService) Cache() *ClusterCache {
+	return s.cache
+}
+
 // AddOrUpdateToolchainCluster takes the ToolchainCluster CR object,
 // creates CachedToolchainCluster with a kube client and stores it in a cache
 func (s *ToolchainClusterService) AddOrUpdateToolchainCluster(cluster *toolchainv1alpha1.ToolchainCluster) error {
@@ -92,14 +129,15 @@ func (s *ToolchainClusterService) addToolchainCluster(log logr.Logger, toolchain
 	}
 
 	var cl client.Client
+	var memberCluster *startedCluster
 	// check if there is already a cached ToolchainCluster so we could reuse the client
 	// we cannot allow to refresh the cache, because the refresh function calls this addToolchainCluster method which results in a recursive loop
-	cachedToolchainCluster, exists := clusterCache.getCachedToolchainCluster(toolchainCluster.Name, false)
+	cachedToolchainCluster, exists := s.cache.getCachedToolchainCluster(toolchainCluster.Name, false)
 	if !exists ||
 		cachedToolchainCluster.Client == nil ||
-		!reflect.DeepEqual(clusterConfig.RestConfig, cachedToolchainCluster.RestConfig) {
+		restConfigChanged(cachedToolchainCluster.Config, clusterConfig) {
 
-		log.Info("creating new client for the cached ToolchainCluster")
+		log.Info("creating new client for the cached ToolchainCluster", "secretResourceVersion", clusterConfig.SecretResourceVersion)
 		scheme := runtime.NewScheme()
 		if err := apis.AddToScheme(scheme); err != nil {
 			return err
@@ -116,15 +154,26 @@ func (s *ToolchainClusterService) addToolchainCluster(log logr.Logger, toolchain
 		if err != nil {
 			return errors.Wrap(err, "cannot create ToolchainCluster client")
 		}
+		if s.newCluster != nil {
+			if memberCluster, err = s.startCluster(log, clusterConfig, scheme); err != nil {
+				return errors.Wrap(err, "cannot create ToolchainCluster cached client")
+			}
+		}
 	} else {
 		// log.Info("reusing the client for the cached ToolchainCluster")
 		cl = cachedToolchainCluster.Client
+		memberCluster = cachedToolchainCluster.cluster
 	}
 
 	cluster := &CachedToolchainCluster{
 		Config:        clusterConfig,
 		Client:        cl,
+		CachedReader:  cl,
 		ClusterStatus: &toolchainCluster.Status,
+		cluster:       memberCluster,
+	}
+	if memberCluster != nil {
+		cluster.CachedReader = memberCluster.GetCache()
 	}
 	if cluster.Type == "" {
 		cluster.Type = Member
@@ -136,7 +185,7 @@ func (s *ToolchainClusterService) addToolchainCluster(log logr.Logger, toolchain
 			cluster.OperatorNamespace = defaultMemberOperatorNamespace
 		}
 	}
-	clusterCache.addCachedToolchainCluster(cluster)
+	s.cache.addCachedToolchainCluster(cluster)
 	return nil
 }
 
@@ -144,7 +193,45 @@ func (s *ToolchainClusterService) addToolchainCluster(log logr.Logger, toolchain
 // and deletes CachedToolchainCluster instance that has same name from a cache (if exists)
 func (s *ToolchainClusterService) DeleteToolchainCluster(name string) {
 	s.log.WithValues("Request.Name", name).Info("observed a deleted cluster")
-	clusterCache.deleteCachedToolchainCluster(name)
+	s.cache.deleteCachedToolchainCluster(name)
+}
+
+// startCluster creates and starts a controller-runtime cluster with the given config, until the context of the service is done
+// or the returned cluster is stopped. It waits (up to the timeout of the service) for the cache of the cluster to be synced,
+// so that the cached reader can be used as soon as the cluster is in the cache. When the cluster stops (eg, because the context
+// of the service is done), the cluster is removed from the cache unless it was already replaced.
+func (s *ToolchainClusterService) startCluster(log logr.Logger, clusterConfig *Config, scheme *runtime.Scheme) (*startedCluster, error) {
+	memberCluster, err := s.newCluster(clusterConfig.RestConfig, func(options *crcluster.Options) {
+		options.Scheme = scheme
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	started := &startedCluster{
+		Cluster: memberCluster,
+		stop:    cancel,
+	}
+	go func() {
+		if err := memberCluster.Start(ctx); err != nil {
+			log.Error(err, "the cached client of the ToolchainCluster failed")
+		}
+		// the cached reader cannot be used anymore
+		cancel()
+		s.cache.deleteCachedToolchainClusterIfStartedWith(clusterConfig.Name, started)
+	}()
+
+	syncCtx := ctx
+	if s.timeout > 0 {
+		var cancelSync context.CancelFunc
+		syncCtx, cancelSync = context.WithTimeout(ctx, s.timeout)
+		defer cancelSync()
+	}
+	if !memberCluster.GetCache().WaitForCacheSync(syncCtx) {
+		cancel()
+		return nil, errors.Errorf("the cache of cluster %s was not synced", clusterConfig.Name)
+	}
+	return started, nil
 }
 
 func (s *ToolchainClusterService) refreshCache() {
@@ -167,7 +254,8 @@ func (s *ToolchainClusterService) enrichLogger(cluster *toolchainv1alpha1.Toolch
 		WithValues("Request.Namespace", cluster.Namespace, "Request.Name", cluster.Name)
 }
 
-// NewClusterConfig generate a new cluster config by fetching the necessary info the given ToolchainCluster's associated Secret and taking all data from ToolchainCluster CR
+// NewClusterConfig generate a new cluster config by fetching the necessary info the given ToolchainCluster's associated Secret and taking all data from ToolchainCluster CR.
+// The Secret contains either a bearer token, a client certificate and key, or a kubeconfig (see `newRestConfigFromSecret`)
 func NewClusterConfig(cl client.Client, toolchainCluster *toolchainv1alpha1.ToolchainCluster, timeout time.Duration) (*Config, error) {
 	clusterName := toolchainCluster.Name
 
@@ -190,38 +278,35 @@ func NewClusterConfig(cl client.Client, toolchainCluster *toolchainv1alpha1.Tool
 		return nil, errors.Wrapf(err, "unable to get secret %s for cluster %s", name, clusterName)
 	}
 
-	token, tokenFound := secret.Data[toolchainTokenKey]
-	if !tokenFound || len(token) == 0 {
-		return nil, errors.Errorf("the secret for cluster %s is missing a non-empty value for %q", clusterName, toolchainTokenKey)
-	}
-
-	restConfig, err := clientcmd.BuildConfigFromFlags(apiEndpoint, "")
+	restConfig, err := newRestConfigFromSecret(clusterName, apiEndpoint, secret)
 	if err != nil {
 		return nil, err
 	}
 
-	if toolchainCluster.Spec.CABundle != "" {
-		ca, err := base64.StdEncoding.DecodeString(toolchainCluster.Spec.CABundle)
-		if err != nil {
-			return nil, err
-		}
-		restConfig.CAData = ca
-	} else {
-		restConfig.Insecure = true
+	if err := configureTLS(restConfig, toolchainCluster); err != nil {
+		return nil, err
 	}
-	restConfig.BearerToken = string(token)
 	restConfig.QPS = toolchainAPIQPS
 	restConfig.Burst = toolchainAPIBurst
 	restConfig.Timeout = timeout
+	if err := applyClientOverrides(restConfig, toolchainCluster); err != nil {
+		return nil, err
+	}
+	proxyURL, err := effectiveProxyURL(restConfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get the proxy of cluster %s", clusterName)
+	}
 
 	return &Config{
-		Name:              toolchainCluster.Name,
-		APIEndpoint:       toolchainCluster.Spec.APIEndpoint,
-		RestConfig:        restConfig,
-		Type:              Type(toolchainCluster.Labels[LabelType]),
-		OperatorNamespace: toolchainCluster.Labels[labelNamespace],
-		OwnerClusterName:  toolchainCluster.Labels[labelOwnerClusterName],
-		Labels:            toolchainCluster.Labels,
+		Name:                  toolchainCluster.Name,
+		APIEndpoint:           toolchainCluster.Spec.APIEndpoint,
+		RestConfig:            restConfig,
+		Type:                  Type(toolchainCluster.Labels[LabelType]),
+		OperatorNamespace:     toolchainCluster.Labels[labelNamespace],
+		OwnerClusterName:      toolchainCluster.Labels[labelOwnerClusterName],
+		Labels:                toolchainCluster.Labels,
+		SecretResourceVersion: secret.ResourceVersion,
+		ProxyURL:              proxyURL,
 	}, nil
 }
 
@@ -1,6 +1,8 @@
 package cluster
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -124,16 +126,128 @@ func TestUpdateClientBasedOnRestConfig(t *testing.T) {
 	})
 }
 
-func newToolchainClusterService(cl client.Client, timeout time.Duration) ToolchainClusterService {
-	return NewToolchainClusterServiceWithClient(cl, logf.Log, "test-namespace", timeout, func(config *rest.Config, options client.Options) (client.Client, error) {
-		// make sure that insecure is false to make Gock mocking working properly
-		// let's use a copy of the config, so it doesn't affect the cache logic
-		copiedConfig := rest.CopyConfig(config)
-		copiedConfig.Insecure = false
-		return client.New(copiedConfig, options)
+func TestClientIsNotRebuiltWithProxy(t *testing.T) {
+	// given
+	defer gock.Off()
+	defer resetClusterCache()
+	status := test.NewClusterStatus(toolchainv1alpha1.ToolchainClusterReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster("east", "secret", status, map[string]string{"type": string(Member)})
+	toolchainCluster.Annotations = map[string]string{ClientProxyURLAnnotation: "http://proxy.com:3128"}
+	cl := test.NewFakeClient(t, sec)
+	// the requests through the proxy are not intercepted by gock
+	service := NewToolchainClusterServiceWithClient(cl, logf.Log, "test-namespace", 3*time.Second, func(config *rest.Config, options client.Options) (client.Client, error) {
+		return test.NewFakeClient(t), nil
+	})
+	require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+	originalClient := clusterCache.clusters["east"].Client
+
+	t.Run("client is reused when the proxy is unchanged", func(t *testing.T) {
+		// when
+		err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.Same(t, originalClient, clusterCache.clusters["east"].Client)
+	})
+
+	t.Run("client is rebuilt when the proxy changes", func(t *testing.T) {
+		// given
+		toolchainCluster.Annotations[ClientProxyURLAnnotation] = "http://other-proxy.com:3128"
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.NotSame(t, originalClient, clusterCache.clusters["east"].Client)
+		assert.Equal(t, "http://other-proxy.com:3128", clusterCache.clusters["east"].ProxyURL)
+	})
+
+	t.Run("client is rebuilt when the proxy of the kubeconfig changes", func(t *testing.T) {
+		// given
+		delete(toolchainCluster.Annotations, ClientProxyURLAnnotation)
+		setKubeconfigProxy := func(proxyURL string) {
+			secret := &corev1.Secret{}
+			require.NoError(t, cl.Get(context.TODO(), test.NamespacedName("test-namespace", "secret"), secret))
+			secret.Data = map[string][]byte{
+				ToolchainKubeconfigKey: []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: member
+  cluster:
+    server: https://ignored.com
+    proxy-url: %s
+users:
+- name: token-user
+  user:
+    token: kubeconfig-token
+contexts:
+- name: token
+  context:
+    cluster: member
+    user: token-user
+current-context: token
+`, proxyURL)),
+			}
+			require.NoError(t, cl.Update(context.TODO(), secret))
+		}
+		setKubeconfigProxy("http://kube-proxy.com:3128")
+		require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+		kubeconfigClient := clusterCache.clusters["east"].Client
+		assert.Equal(t, "http://kube-proxy.com:3128", clusterCache.clusters["east"].ProxyURL)
+		setKubeconfigProxy("http://other-kube-proxy.com:3128")
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.NotSame(t, kubeconfigClient, clusterCache.clusters["east"].Client)
+		assert.Equal(t, "http://other-kube-proxy.com:3128", clusterCache.clusters["east"].ProxyURL)
 	})
 }
 
+func TestServicesWithIsolatedCaches(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ToolchainClusterReady, corev1.ConditionTrue)
+	east, eastSecret := test.NewToolchainCluster("east", "secret1", status, map[string]string{"type": string(Member)})
+	west, westSecret := test.NewToolchainCluster("west", "secret2", status, map[string]string{"type": string(Member)})
+	eastCache := NewClusterCache()
+	westCache := NewClusterCache()
+	eastService := NewToolchainClusterServiceWithCache(eastCache, test.NewFakeClient(t, east, eastSecret), logf.Log, "test-namespace", 0, newTestClient)
+	westService := NewToolchainClusterServiceWithCache(westCache, test.NewFakeClient(t, west, westSecret), logf.Log, "test-namespace", 0, newTestClient)
+
+	// when
+	eastClusters := eastService.Cache().GetMemberClusters()
+	westClusters := westService.Cache().GetMemberClusters()
+
+	// then
+	require.Len(t, eastClusters, 1)
+	assert.Equal(t, "east", eastClusters[0].Name)
+	require.Len(t, westClusters, 1)
+	assert.Equal(t, "west", westClusters[0].Name)
+	_, ok := eastCache.GetCachedToolchainCluster("west")
+	assert.False(t, ok)
+	_, ok = westCache.GetCachedToolchainCluster("east")
+	assert.False(t, ok)
+	// the default cache is not filled by the services
+	_, ok = GetCachedToolchainCluster("east")
+	assert.False(t, ok)
+}
+
+func newToolchainClusterService(cl client.Client, timeout time.Duration) ToolchainClusterService {
+	return NewToolchainClusterServiceWithClient(cl, logf.Log, "test-namespace", timeout, newTestClient)
+}
+
+func newTestClient(config *rest.Config, options client.Options) (client.Client, error) {
+	// make sure that insecure is false to make Gock mocking working properly
+	// let's use a copy of the config, so it doesn't affect the cache logic
+	copiedConfig := rest.CopyConfig(config)
+	copiedConfig.Insecure = false
+	return client.New(copiedConfig, options)
+}
+
 func assertMemberCluster(t *testing.T, cachedCluster *CachedToolchainCluster, status toolchainv1alpha1.ToolchainClusterStatus) {
 	assert.Equal(t, Member, cachedCluster.Type)
 	assert.Equal(t, status, *cachedCluster.ClusterStatus)
@@ -0,0 +1,44 @@
+package cluster
+
+import (
+	"encoding/base64"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+// InsecureSkipTLSVerifyAnnotation the annotation to set to "true" on a ToolchainCluster to explicitly opt in for an insecure connection,
+// ie, without the verification of the certificate of the cluster
+const InsecureSkipTLSVerifyAnnotation = toolchainv1alpha1.LabelKeyPrefix + "insecure-skip-tls-verify"
+
+// IsInsecureSkipTLSVerify returns `true` if the given ToolchainCluster explicitly opted in for an insecure connection
+// (see `InsecureSkipTLSVerifyAnnotation`)
+func IsInsecureSkipTLSVerify(toolchainCluster *toolchainv1alpha1.ToolchainCluster) bool {
+	return toolchainCluster.Annotations[InsecureSkipTLSVerifyAnnotation] == "true"
+}
+
+// configureTLS configures the verification of the certificate of the cluster:
+// - if the ToolchainCluster opted in for an insecure connection, then the certificate is not verified,
+// - otherwise, if the ToolchainCluster has a CA bundle, then the certificate is verified against this bundle,
+// - otherwise, the certificate is verified against the CA of the kubeconfig (if any), or against the system CA pool.
+// An insecure connection is never allowed without the explicit opt-in, even if the kubeconfig skips the verification.
+func configureTLS(restConfig *rest.Config, toolchainCluster *toolchainv1alpha1.ToolchainCluster) error {
+	if IsInsecureSkipTLSVerify(toolchainCluster) {
+		// a CA cannot be specified along with the insecure flag
+		restConfig.Insecure = true
+		restConfig.CAFile = ""
+		restConfig.CAData = nil
+		return nil
+	}
+	restConfig.Insecure = false
+	if toolchainCluster.Spec.CABundle != "" {
+		ca, err := base64.StdEncoding.DecodeString(toolchainCluster.Spec.CABundle)
+		if err != nil {
+			return errors.Wrapf(err, "the CA bundle of cluster %s is invalid", toolchainCluster.Name)
+		}
+		restConfig.CAFile = ""
+		restConfig.CAData = ca
+	}
+	return nil
+}
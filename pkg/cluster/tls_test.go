@@ -0,0 +1,105 @@
+package cluster_test
+
+import (
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test/verify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const insecureKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: member
+  cluster:
+    server: https://ignored.com
+    insecure-skip-tls-verify: true
+users:
+- name: token-user
+  user:
+    token: kubeconfig-token
+contexts:
+- name: token
+  context:
+    cluster: member
+    user: token-user
+current-context: token
+`
+
+func TestNewClusterConfigTLS(t *testing.T) {
+	status := test.NewClusterStatus(toolchainv1alpha1.ToolchainClusterReady, corev1.ConditionTrue)
+	newClusterConfig := func(t *testing.T, caBundle string, annotations map[string]string, data map[string][]byte) (*cluster.Config, error) {
+		toolchainCluster, secret := test.NewToolchainCluster("east", "secret", status, verify.Labels(cluster.Member, "", test.NameHost))
+		toolchainCluster.Spec.CABundle = caBundle
+		toolchainCluster.Annotations = annotations
+		if data != nil {
+			secret.Data = data
+		}
+		cl := test.NewFakeClient(t, toolchainCluster, secret)
+		return cluster.NewClusterConfig(cl, toolchainCluster, time.Second)
+	}
+
+	t.Run("system CA pool without CA bundle", func(t *testing.T) {
+		// when
+		config, err := newClusterConfig(t, "", nil, nil)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, config.RestConfig.Insecure)
+		assert.Empty(t, config.RestConfig.CAData)
+		assert.Empty(t, config.RestConfig.CAFile)
+	})
+
+	t.Run("CA bundle", func(t *testing.T) {
+		// when
+		config, err := newClusterConfig(t, "ZHVtbXk=", nil, nil)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, config.RestConfig.Insecure)
+		assert.Equal(t, []byte("dummy"), config.RestConfig.CAData)
+	})
+
+	t.Run("insecure with explicit opt-in", func(t *testing.T) {
+		// when
+		config, err := newClusterConfig(t, "ZHVtbXk=", map[string]string{cluster.InsecureSkipTLSVerifyAnnotation: "true"}, nil)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, config.RestConfig.Insecure)
+		assert.Empty(t, config.RestConfig.CAData)
+	})
+
+	t.Run("not insecure with other annotation value", func(t *testing.T) {
+		// when
+		config, err := newClusterConfig(t, "", map[string]string{cluster.InsecureSkipTLSVerifyAnnotation: "yes"}, nil)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, config.RestConfig.Insecure)
+	})
+
+	t.Run("insecure kubeconfig without explicit opt-in", func(t *testing.T) {
+		// when
+		config, err := newClusterConfig(t, "", nil, map[string][]byte{"kubeconfig": []byte(insecureKubeconfig)})
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, config.RestConfig.Insecure)
+	})
+
+	t.Run("invalid CA bundle", func(t *testing.T) {
+		// when
+		_, err := newClusterConfig(t, "not-base64!", nil, nil)
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the CA bundle of cluster east is invalid")
+	})
+}
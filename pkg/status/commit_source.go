@@ -0,0 +1,122 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/google/go-github/v52/github"
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Commit the latest commit of a branch of a repository
+type Commit struct {
+	// SHA the SHA of the commit
+	SHA string `json:"sha"`
+	// Timestamp the time of the commit. It's zero if the commit source cannot provide it.
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
+
+// CommitSource provides the latest commit of a branch of a repository
+type CommitSource interface {
+	// LatestCommit returns the latest commit of the branch of the given repository
+	LatestCommit(ctx context.Context, repo client.GitHubRepository) (Commit, error)
+}
+
+// GitHubCommitSource gets the latest commits from the GitHub API
+type GitHubCommitSource struct {
+	Client *github.Client
+}
+
+// NewGitHubCommitSource returns a new commit source which uses the given GitHub client
+func NewGitHubCommitSource(githubClient *github.Client) *GitHubCommitSource {
+	return &GitHubCommitSource{
+		Client: githubClient,
+	}
+}
+
+// LatestCommit returns the latest commit of the branch of the given repository.
+// Returns a `*github.RateLimitError` if the rate limit of the GitHub API is exceeded.
+func (s *GitHubCommitSource) LatestCommit(ctx context.Context, repo client.GitHubRepository) (Commit, error) {
+	latestCommit, commitResponse, err := s.Client.Repositories.GetCommit(ctx, repo.Org, repo.Name, repo.Branch, &github.ListOptions{})
+	if commitResponse != nil {
+		defer commitResponse.Body.Close()
+	}
+	var rateLimitErr *github.RateLimitError
+	if errs.As(err, &rateLimitErr) {
+		return Commit{}, err
+	}
+	if err != nil {
+		if ghErr, ok := err.(*github.ErrorResponse); ok { //nolint:errorlint
+			return Commit{}, errs.New(ghErr.Message) // this strips out the URL called, useful when unit testing since the port changes with each test execution.
+		}
+		return Commit{}, err
+	}
+	if commitResponse.StatusCode != http.StatusOK {
+		return Commit{}, fmt.Errorf("invalid response code from github commits API. resp.Response.StatusCode: %d, repoName: %s, repoBranch: %s", commitResponse.Response.StatusCode, repo.Name, repo.Branch)
+	}
+	if reflect.DeepEqual(latestCommit, &github.RepositoryCommit{}) {
+		return Commit{}, fmt.Errorf("no commits returned. repoName: %s, repoBranch: %s", repo.Name, repo.Branch)
+	}
+	return Commit{
+		SHA:       latestCommit.GetSHA(),
+		Timestamp: latestCommit.GetCommit().GetAuthor().GetDate().Time,
+	}, nil
+}
+
+// StaticCommitSource provides the commits from a static list, eg, for air-gapped installations which cannot reach any Git server.
+// The commits are indexed by the name of the repository.
+type StaticCommitSource struct {
+	Commits map[string]Commit
+}
+
+// NewStaticCommitSource returns a new commit source with the given commits, indexed by the name of the repository
+func NewStaticCommitSource(commits map[string]Commit) *StaticCommitSource {
+	return &StaticCommitSource{
+		Commits: commits,
+	}
+}
+
+// NewStaticCommitSourceFromConfigMap returns a new commit source with the commits defined in the given ConfigMap:
+// each key is the name of a repository, and each value is a YAML or JSON object with the `sha` and the (optional) RFC3339 `timestamp` of the commit.
+func NewStaticCommitSourceFromConfigMap(cm *corev1.ConfigMap) (*StaticCommitSource, error) {
+	commits := make(map[string]Commit, len(cm.Data))
+	for repo, value := range cm.Data {
+		commit := Commit{}
+		if err := yaml.Unmarshal([]byte(value), &commit); err != nil {
+			return nil, errs.Wrapf(err, "invalid commit of the repository '%s' in the ConfigMap '%s'", repo, cm.Name)
+		}
+		commits[repo] = commit
+	}
+	return NewStaticCommitSource(commits), nil
+}
+
+// NewStaticCommitSourceFromFile returns a new commit source with the commits defined in the given YAML or JSON file,
+// which contains an object whose keys are the names of the repositories, and whose values are the commits
+// (with their `sha` and (optional) RFC3339 `timestamp`)
+func NewStaticCommitSourceFromFile(path string) (*StaticCommitSource, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to read the commits file '%s'", path)
+	}
+	commits := map[string]Commit{}
+	if err := yaml.Unmarshal(content, &commits); err != nil {
+		return nil, errs.Wrapf(err, "invalid commits file '%s'", path)
+	}
+	return NewStaticCommitSource(commits), nil
+}
+
+// LatestCommit returns the commit of the given repository (regardless of the branch)
+func (s *StaticCommitSource) LatestCommit(_ context.Context, repo client.GitHubRepository) (Commit, error) {
+	commit, found := s.Commits[repo.Name]
+	if !found || commit.SHA == "" {
+		return Commit{}, fmt.Errorf("no commit defined for the repository '%s'", repo.Name)
+	}
+	return commit, nil
+}
@@ -0,0 +1,117 @@
+package status
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	errs "github.com/pkg/errors"
+)
+
+// GitCommitSource gets the latest commits from any Git server which supports the smart HTTP protocol, the same way as `git ls-remote`.
+// The protocol does not provide the time of the commits, so the returned commits have no timestamp.
+type GitCommitSource struct {
+	// BaseURL the URL of the Git server, eg: `https://github.com`. The URL of a repository is `<BaseURL>/<Org>/<Name>.git`
+	BaseURL string
+	// Username and Password the (optional) credentials for the basic authentication
+	Username, Password string
+	// HTTPClient the HTTP client used to send the requests (default: `http.DefaultClient`)
+	HTTPClient *http.Client
+}
+
+// NewGitCommitSource returns a new commit source for the Git server with the given URL
+func NewGitCommitSource(baseURL string) *GitCommitSource {
+	return &GitCommitSource{
+		BaseURL: baseURL,
+	}
+}
+
+// LatestCommit returns the commit the branch of the given repository points to. If the branch is `HEAD`, then
+// the commit the default branch points to is returned.
+func (s *GitCommitSource) LatestCommit(ctx context.Context, repo client.GitHubRepository) (Commit, error) {
+	u := fmt.Sprintf("%s/%s/%s.git/info/refs?service=git-upload-pack", strings.TrimSuffix(s.BaseURL, "/"), repo.Org, repo.Name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Commit{}, errs.Wrapf(err, "invalid Git URL '%s'", u)
+	}
+	if s.Username != "" || s.Password != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+	resp, err := httpClientOrDefault(s.HTTPClient).Do(req)
+	if err != nil {
+		return Commit{}, errs.Wrapf(err, "unable to list the references of the Git repository. repoName: %s", repo.Name)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Commit{}, fmt.Errorf("invalid response code from Git server. resp.Response.StatusCode: %d, repoName: %s", resp.StatusCode, repo.Name)
+	}
+	refs, err := readAdvertisedRefs(resp.Body)
+	if err != nil {
+		return Commit{}, errs.Wrapf(err, "invalid response from Git server. repoName: %s", repo.Name)
+	}
+	ref := repo.Branch
+	if ref != "HEAD" && !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+	sha, found := refs[ref]
+	if !found {
+		return Commit{}, fmt.Errorf("no commits returned. repoName: %s, repoBranch: %s", repo.Name, repo.Branch)
+	}
+	return Commit{
+		SHA: sha,
+	}, nil
+}
+
+// readAdvertisedRefs reads the references advertised by a Git server (in the pkt-line format) and returns the SHA of each reference
+func readAdvertisedRefs(r io.Reader) (map[string]string, error) {
+	refs := map[string]string{}
+	reader := bufio.NewReader(r)
+	for {
+		line, flush, err := readPktLine(reader)
+		if err == io.EOF {
+			return refs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if flush || strings.HasPrefix(line, "# service=") {
+			continue
+		}
+		// the first reference is followed by the capabilities
+		line = strings.SplitN(line, "\x00", 2)[0]
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		refs[fields[1]] = fields[0]
+	}
+}
+
+// readPktLine reads a line in the pkt-line format: 4 hexadecimal digits for the length (including the 4 digits), followed by the content.
+// Returns `true` if the line is a flush packet (`0000`).
+func readPktLine(reader *bufio.Reader) (string, bool, error) {
+	prefix := make([]byte, 4)
+	if _, err := io.ReadFull(reader, prefix); err != nil {
+		return "", false, err
+	}
+	length, err := strconv.ParseUint(string(prefix), 16, 16)
+	if err != nil {
+		return "", false, errs.Wrapf(err, "invalid pkt-line length '%s'", string(prefix))
+	}
+	if length == 0 {
+		return "", true, nil
+	}
+	if length < 4 {
+		return "", false, fmt.Errorf("invalid pkt-line length '%s'", string(prefix))
+	}
+	content := make([]byte, length-4)
+	if _, err := io.ReadFull(reader, content); err != nil {
+		return "", false, err
+	}
+	return strings.TrimSuffix(string(content), "\n"), false, nil
+}
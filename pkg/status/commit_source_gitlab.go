@@ -0,0 +1,77 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	errs "github.com/pkg/errors"
+)
+
+// GitLabCommitSource gets the latest commits from the REST API (v4) of a GitLab instance.
+// The organization of the repositories is the namespace (group) of the GitLab projects.
+type GitLabCommitSource struct {
+	// BaseURL the URL of the GitLab instance, eg: `https://gitlab.com`
+	BaseURL string
+	// Token the (optional) access token, sent in the `PRIVATE-TOKEN` header
+	Token string
+	// HTTPClient the HTTP client used to send the requests (default: `http.DefaultClient`)
+	HTTPClient *http.Client
+}
+
+// NewGitLabCommitSource returns a new commit source for the GitLab instance with the given URL, using the given (optional) access token
+func NewGitLabCommitSource(baseURL, token string) *GitLabCommitSource {
+	return &GitLabCommitSource{
+		BaseURL: baseURL,
+		Token:   token,
+	}
+}
+
+type gitLabCommit struct {
+	ID            string    `json:"id"`
+	CommittedDate time.Time `json:"committed_date"`
+}
+
+// LatestCommit returns the latest commit of the branch of the given repository
+func (s *GitLabCommitSource) LatestCommit(ctx context.Context, repo client.GitHubRepository) (Commit, error) {
+	project := url.PathEscape(repo.Org + "/" + repo.Name)
+	u := fmt.Sprintf("%s/api/v4/projects/%s/repository/commits/%s", strings.TrimSuffix(s.BaseURL, "/"), project, url.PathEscape(repo.Branch))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Commit{}, errs.Wrapf(err, "invalid GitLab URL '%s'", u)
+	}
+	if s.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", s.Token)
+	}
+	resp, err := httpClientOrDefault(s.HTTPClient).Do(req)
+	if err != nil {
+		return Commit{}, errs.Wrapf(err, "unable to get the latest commit from GitLab. repoName: %s, repoBranch: %s", repo.Name, repo.Branch)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Commit{}, fmt.Errorf("invalid response code from gitlab commits API. resp.Response.StatusCode: %d, repoName: %s, repoBranch: %s", resp.StatusCode, repo.Name, repo.Branch)
+	}
+	commit := gitLabCommit{}
+	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
+		return Commit{}, errs.Wrapf(err, "invalid response from gitlab commits API. repoName: %s, repoBranch: %s", repo.Name, repo.Branch)
+	}
+	if commit.ID == "" {
+		return Commit{}, fmt.Errorf("no commits returned. repoName: %s, repoBranch: %s", repo.Name, repo.Branch)
+	}
+	return Commit{
+		SHA:       commit.ID,
+		Timestamp: commit.CommittedDate,
+	}, nil
+}
+
+func httpClientOrDefault(cl *http.Client) *http.Client {
+	if cl == nil {
+		return http.DefaultClient
+	}
+	return cl
+}
@@ -0,0 +1,352 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var hostOperatorRepo = client.GitHubRepository{
+	Org:               toolchainv1alpha1.ProviderLabelValue,
+	Name:              "host-operator",
+	Branch:            "master",
+	DeployedCommitSHA: "1234abcd",
+}
+
+func TestGitLabCommitSource(t *testing.T) {
+	newServer := func(t *testing.T, status int, body string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.EscapedPath() != "/api/v4/projects/codeready-toolchain%2Fhost-operator/repository/commits/master" || r.Header.Get("PRIVATE-TOKEN") != "glpat" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(status)
+			_, err := w.Write([]byte(body))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	t.Run("latest commit", func(t *testing.T) {
+		// given
+		server := newServer(t, http.StatusOK, `{"id":"1234abcd","committed_date":"2023-05-04T10:20:30.000+02:00"}`)
+		source := NewGitLabCommitSource(server.URL+"/", "glpat")
+
+		// when
+		commit, err := source.LatestCommit(context.TODO(), hostOperatorRepo)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "1234abcd", commit.SHA)
+		assert.True(t, time.Date(2023, 5, 4, 8, 20, 30, 0, time.UTC).Equal(commit.Timestamp))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		t.Run("invalid response code", func(t *testing.T) {
+			// given
+			server := newServer(t, http.StatusInternalServerError, "")
+			source := NewGitLabCommitSource(server.URL, "glpat")
+
+			// when
+			_, err := source.LatestCommit(context.TODO(), hostOperatorRepo)
+
+			// then
+			require.EqualError(t, err, "invalid response code from gitlab commits API. resp.Response.StatusCode: 500, repoName: host-operator, repoBranch: master")
+		})
+
+		t.Run("missing token", func(t *testing.T) {
+			// given
+			server := newServer(t, http.StatusOK, `{"id":"1234abcd"}`)
+			source := NewGitLabCommitSource(server.URL, "")
+
+			// when
+			_, err := source.LatestCommit(context.TODO(), hostOperatorRepo)
+
+			// then
+			require.EqualError(t, err, "invalid response code from gitlab commits API. resp.Response.StatusCode: 404, repoName: host-operator, repoBranch: master")
+		})
+
+		t.Run("no commit", func(t *testing.T) {
+			// given
+			server := newServer(t, http.StatusOK, `{}`)
+			source := NewGitLabCommitSource(server.URL, "glpat")
+
+			// when
+			_, err := source.LatestCommit(context.TODO(), hostOperatorRepo)
+
+			// then
+			require.EqualError(t, err, "no commits returned. repoName: host-operator, repoBranch: master")
+		})
+	})
+}
+
+func TestGitCommitSource(t *testing.T) {
+	pktLine := func(line string) string {
+		return fmt.Sprintf("%04x%s", len(line)+4, line)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/codeready-toolchain/host-operator.git/info/refs" || r.URL.Query().Get("service") != "git-upload-pack" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, err := w.Write([]byte(pktLine("# service=git-upload-pack\n") + "0000" +
+			pktLine("5678efgh HEAD\x00multi_ack symref=HEAD:refs/heads/master\n") +
+			pktLine("5678efgh refs/heads/master\n") +
+			pktLine("9012ijkl refs/heads/release\n") +
+			pktLine("3456mnop refs/tags/v1.0.0\n") + "0000"))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+	source := NewGitCommitSource(server.URL)
+
+	for branch, sha := range map[string]string{
+		"HEAD":               "5678efgh",
+		"master":             "5678efgh",
+		"release":            "9012ijkl",
+		"refs/tags/v1.0.0":   "3456mnop",
+		"refs/heads/release": "9012ijkl",
+	} {
+		t.Run(branch, func(t *testing.T) {
+			// given
+			repo := hostOperatorRepo
+			repo.Branch = branch
+
+			// when
+			commit, err := source.LatestCommit(context.TODO(), repo)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, Commit{SHA: sha}, commit)
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		t.Run("unknown branch", func(t *testing.T) {
+			// given
+			repo := hostOperatorRepo
+			repo.Branch = "unknown"
+
+			// when
+			_, err := source.LatestCommit(context.TODO(), repo)
+
+			// then
+			require.EqualError(t, err, "no commits returned. repoName: host-operator, repoBranch: unknown")
+		})
+
+		t.Run("unknown repository", func(t *testing.T) {
+			// given
+			repo := hostOperatorRepo
+			repo.Name = "unknown"
+
+			// when
+			_, err := source.LatestCommit(context.TODO(), repo)
+
+			// then
+			require.EqualError(t, err, "invalid response code from Git server. resp.Response.StatusCode: 404, repoName: unknown")
+		})
+
+		t.Run("invalid pkt-line", func(t *testing.T) {
+			// given
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, err := w.Write([]byte("zzzz"))
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+
+			// when
+			_, err := NewGitCommitSource(server.URL).LatestCommit(context.TODO(), hostOperatorRepo)
+
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid response from Git server. repoName: host-operator: invalid pkt-line length 'zzzz'")
+		})
+	})
+}
+
+func TestStaticCommitSource(t *testing.T) {
+	timestamp := time.Date(2023, 5, 4, 10, 20, 30, 0, time.UTC)
+
+	t.Run("from ConfigMap", func(t *testing.T) {
+		// given
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "commits"},
+			Data: map[string]string{
+				"host-operator":        "sha: 1234abcd\ntimestamp: 2023-05-04T10:20:30Z",
+				"registration-service": `{"sha":"5678efgh"}`,
+			},
+		}
+
+		// when
+		source, err := NewStaticCommitSourceFromConfigMap(cm)
+
+		// then
+		require.NoError(t, err)
+		commit, err := source.LatestCommit(context.TODO(), hostOperatorRepo)
+		require.NoError(t, err)
+		assert.Equal(t, "1234abcd", commit.SHA)
+		assert.True(t, timestamp.Equal(commit.Timestamp))
+		commit, err = source.LatestCommit(context.TODO(), client.GitHubRepository{Name: "registration-service"})
+		require.NoError(t, err)
+		assert.Equal(t, Commit{SHA: "5678efgh"}, commit)
+		_, err = source.LatestCommit(context.TODO(), client.GitHubRepository{Name: "member-operator"})
+		require.EqualError(t, err, "no commit defined for the repository 'member-operator'")
+	})
+
+	t.Run("from invalid ConfigMap", func(t *testing.T) {
+		// given
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "commits"},
+			Data: map[string]string{
+				"host-operator": "sha: [1234abcd",
+			},
+		}
+
+		// when
+		_, err := NewStaticCommitSourceFromConfigMap(cm)
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid commit of the repository 'host-operator' in the ConfigMap 'commits'")
+	})
+
+	t.Run("from file", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "commits.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("host-operator:\n  sha: 1234abcd\n  timestamp: 2023-05-04T10:20:30Z\n"), 0600))
+
+		// when
+		source, err := NewStaticCommitSourceFromFile(path)
+
+		// then
+		require.NoError(t, err)
+		commit, err := source.LatestCommit(context.TODO(), hostOperatorRepo)
+		require.NoError(t, err)
+		assert.Equal(t, "1234abcd", commit.SHA)
+		assert.True(t, timestamp.Equal(commit.Timestamp))
+	})
+
+	t.Run("from missing file", func(t *testing.T) {
+		// when
+		_, err := NewStaticCommitSourceFromFile(filepath.Join(t.TempDir(), "commits.yaml"))
+
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to read the commits file")
+	})
+}
+
+func TestCheckDeployedVersionWithCommitSource(t *testing.T) {
+	upToDate := toolchainv1alpha1.Condition{
+		Type:   toolchainv1alpha1.ConditionReady,
+		Status: corev1.ConditionTrue,
+		Reason: toolchainv1alpha1.ToolchainStatusDeploymentUpToDateReason,
+	}
+
+	t.Run("access token key is not required", func(t *testing.T) {
+		// given
+		versionCheckMgr := VersionCheckManager{
+			CommitSource: NewStaticCommitSource(map[string]Commit{
+				"host-operator": {SHA: "1234abcd", Timestamp: time.Now().Add(-time.Hour)},
+			}),
+		}
+
+		// when
+		cond := versionCheckMgr.CheckDeployedVersionIsUpToDate(true, "", []toolchainv1alpha1.Condition{}, hostOperatorRepo)
+
+		// then
+		test.AssertConditionsMatchAndRecentTimestamps(t, []toolchainv1alpha1.Condition{*cond}, upToDate)
+	})
+
+	t.Run("deployment is not up to date", func(t *testing.T) {
+		// given
+		timestamp := time.Now().Add(-time.Hour)
+		versionCheckMgr := VersionCheckManager{
+			CommitSource: NewStaticCommitSource(map[string]Commit{
+				"host-operator": {SHA: "5678efgh", Timestamp: timestamp},
+			}),
+		}
+
+		// when
+		cond := versionCheckMgr.CheckDeployedVersionIsUpToDate(true, "", []toolchainv1alpha1.Condition{}, hostOperatorRepo)
+
+		// then
+		test.AssertConditionsMatchAndRecentTimestamps(t, []toolchainv1alpha1.Condition{*cond}, toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  toolchainv1alpha1.ToolchainStatusDeploymentNotUpToDateReason,
+			Message: "deployment version is not up to date with latest github commit SHA. deployed commit SHA 1234abcd ,github latest SHA 5678efgh, expected deployment timestamp: " + timestamp.Add(DeploymentThreshold).Format(time.RFC3339),
+		})
+	})
+
+	t.Run("commit without timestamp", func(t *testing.T) {
+		// given
+		versionCheckMgr := VersionCheckManager{
+			CommitSource:       NewStaticCommitSource(map[string]Commit{"host-operator": {SHA: "5678efgh"}}),
+			LastGHCallsPerRepo: map[string]time.Time{},
+			GHCallsDelay:       time.Nanosecond,
+		}
+
+		t.Run("within the threshold since the commit was first seen", func(t *testing.T) {
+			// when
+			cond := versionCheckMgr.CheckDeployedVersionIsUpToDate(true, "", []toolchainv1alpha1.Condition{}, hostOperatorRepo)
+
+			// then
+			test.AssertConditionsMatchAndRecentTimestamps(t, []toolchainv1alpha1.Condition{*cond}, upToDate)
+		})
+
+		t.Run("threshold expired since the commit was first seen", func(t *testing.T) {
+			// given
+			firstSeen := time.Now().Add(-time.Hour)
+			versionCheckMgr.firstSeenCommits["host-operator"] = Commit{SHA: "5678efgh", Timestamp: firstSeen}
+
+			// when
+			cond := versionCheckMgr.CheckDeployedVersionIsUpToDate(true, "", []toolchainv1alpha1.Condition{}, hostOperatorRepo)
+
+			// then
+			assert.Equal(t, toolchainv1alpha1.ToolchainStatusDeploymentNotUpToDateReason, cond.Reason)
+		})
+
+		t.Run("new commit resets the first seen time", func(t *testing.T) {
+			// given
+			versionCheckMgr.CommitSource = NewStaticCommitSource(map[string]Commit{"host-operator": {SHA: "9012ijkl"}})
+
+			// when
+			cond := versionCheckMgr.CheckDeployedVersionIsUpToDate(true, "", []toolchainv1alpha1.Condition{}, hostOperatorRepo)
+
+			// then
+			test.AssertConditionsMatchAndRecentTimestamps(t, []toolchainv1alpha1.Condition{*cond}, upToDate)
+		})
+	})
+
+	t.Run("error from commit source", func(t *testing.T) {
+		// given
+		versionCheckMgr := VersionCheckManager{
+			CommitSource: NewStaticCommitSource(map[string]Commit{}),
+		}
+
+		// when
+		cond := versionCheckMgr.CheckDeployedVersionIsUpToDate(true, "", []toolchainv1alpha1.Condition{}, hostOperatorRepo)
+
+		// then
+		test.AssertConditionsMatchAndRecentTimestamps(t, []toolchainv1alpha1.Condition{*cond}, toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  toolchainv1alpha1.ToolchainStatusDeploymentRevisionCheckGitHubErrorReason,
+			Message: "no commit defined for the repository 'host-operator'",
+		})
+	})
+}
@@ -3,8 +3,6 @@ package status
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"reflect"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
@@ -24,8 +22,19 @@ const (
 )
 
 type VersionCheckManager struct {
+	// GetGithubClientFunc the func which returns the GitHub client for a given access token, eg, `client.NewGitHubClient`,
+	// or the func returned by `client.NewGitHubAppClientFunc` to authenticate as a GitHub App (in which case the access token is ignored)
 	GetGithubClientFunc client.GetGitHubClientFunc
 	LastGHCallsPerRepo  map[string]time.Time
+	// GHCallsDelay the minimum delay between two calls to the GitHub API for the same repository (default: `client.GitHubAPICallDelay`).
+	// The clients returned by `client.NewGitHubClient` send conditional requests which do not count against the rate limit,
+	// so the delay can be shorter.
+	GHCallsDelay time.Duration
+	// CommitSource the source of the latest commits of the repositories (default: the GitHub API, with the client returned by `GetGithubClientFunc`).
+	// Eg, `GitLabCommitSource`, `GitCommitSource` or `StaticCommitSource` for the environments which cannot reach github.com.
+	CommitSource CommitSource
+	// firstSeenCommits the latest commit per repo, with the time it was first returned by a commit source which does not provide the timestamps
+	firstSeenCommits map[string]Commit
 }
 
 // CheckDeployedVersionIsUpToDate verifies if there is a match between the latest commit in GitHub for a given repo and branch matches the provided commit SHA.
@@ -37,7 +46,7 @@ func (m *VersionCheckManager) CheckDeployedVersionIsUpToDate(isProd bool, access
 		cond.Message = "is not running in prod environment"
 		return cond
 	}
-	if accessTokenKey == "" {
+	if m.CommitSource == nil && accessTokenKey == "" {
 		cond := NewComponentReadyCondition(toolchainv1alpha1.ToolchainStatusDeploymentRevisionCheckDisabledReason)
 		cond.Message = "access token key is not provided"
 		return cond
@@ -46,48 +55,69 @@ func (m *VersionCheckManager) CheckDeployedVersionIsUpToDate(isProd bool, access
 	if m.LastGHCallsPerRepo == nil {
 		m.LastGHCallsPerRepo = map[string]time.Time{}
 	}
+	delay := m.GHCallsDelay
+	if delay == 0 {
+		delay = client.GitHubAPICallDelay
+	}
 	lastCall, present := m.LastGHCallsPerRepo[githubRepo.Name]
-	if present && !client.CanIssueGitHubRequest(lastCall) {
+	if present && !client.CanIssueGitHubRequestWithDelay(lastCall, delay) {
 		// return existing condition when we cannot make a new GitHub api call due to rate limiting issues.
-		previouslySet, found := condition.FindConditionByType(alreadyExistingConditions, toolchainv1alpha1.ConditionReady)
-		if !found {
-			cond := NewComponentErrorCondition(toolchainv1alpha1.ToolchainStatusDeploymentRevisionCheckOperatorErrorReason, "unable to find ConditionReady type in existing conditions. Waiting for next attempt ...")
-			return cond
-		}
-		return &previouslySet
+		return existingReadyCondition(alreadyExistingConditions)
 	}
 	m.LastGHCallsPerRepo[githubRepo.Name] = time.Now()
-	githubClient := m.GetGithubClientFunc(accessTokenKey)
-	// get the latest commit from given repository and branch
-	latestCommit, commitResponse, err := githubClient.Repositories.GetCommit(context.TODO(), githubRepo.Org, githubRepo.Name, githubRepo.Branch, &github.ListOptions{})
-	defer commitResponse.Body.Close()
-	if err != nil {
-		errMsg := err.Error()
-		if ghErr, ok := err.(*github.ErrorResponse); ok { //nolint:errorlint
-			errMsg = ghErr.Message // this strips out the URL called, useful when unit testing since the port changes with each test execution.
-		}
-		return NewComponentErrorCondition(toolchainv1alpha1.ToolchainStatusDeploymentRevisionCheckGitHubErrorReason, errMsg)
+	commitSource := m.CommitSource
+	if commitSource == nil {
+		commitSource = NewGitHubCommitSource(m.GetGithubClientFunc(accessTokenKey))
 	}
-	if commitResponse.StatusCode != http.StatusOK {
-		err = errs.New(fmt.Sprintf("invalid response code from github commits API. resp.Response.StatusCode: %d, repoName: %s, repoBranch: %s", commitResponse.Response.StatusCode, githubRepo.Name, githubRepo.Branch))
-		return NewComponentErrorCondition(toolchainv1alpha1.ToolchainStatusDeploymentRevisionCheckGitHubErrorReason, err.Error())
+	// get the latest commit from given repository and branch
+	latestCommit, err := commitSource.LatestCommit(context.TODO(), githubRepo)
+	var rateLimitErr *github.RateLimitError
+	if errs.As(err, &rateLimitErr) {
+		// return existing condition until the rate limit is reset
+		return existingReadyCondition(alreadyExistingConditions)
 	}
-
-	if reflect.DeepEqual(latestCommit, &github.RepositoryCommit{}) {
-		err = errs.New(fmt.Sprintf("no commits returned. repoName: %s, repoBranch: %s", githubRepo.Name, githubRepo.Branch))
+	if err != nil {
 		return NewComponentErrorCondition(toolchainv1alpha1.ToolchainStatusDeploymentRevisionCheckGitHubErrorReason, err.Error())
 	}
 	// check if there is a mismatch between the commit id of the running version and latest commit id from the source code repo (deployed version according to GitHub actions)
 	// we also consider some delay ( time that usually takes the deployment to happen on all our environments)
-	githubCommitTimestamp := latestCommit.Commit.Author.GetDate()
-	expectedDeploymentTime := githubCommitTimestamp.Add(DeploymentThreshold) // let's consider some threshold for the deployment to happen
-	githubCommitSHA := *latestCommit.SHA
-	if githubCommitSHA != githubRepo.DeployedCommitSHA && time.Now().After(expectedDeploymentTime) {
+	expectedDeploymentTime := m.commitTimestamp(githubRepo, latestCommit).Add(DeploymentThreshold) // let's consider some threshold for the deployment to happen
+	if latestCommit.SHA != githubRepo.DeployedCommitSHA && time.Now().After(expectedDeploymentTime) {
 		// deployed version is not up-to-date after expected threshold
-		err := fmt.Errorf("%s. deployed commit SHA %s ,github latest SHA %s, expected deployment timestamp: %s", ErrMsgDeploymentIsNotUpToDate, githubRepo.DeployedCommitSHA, githubCommitSHA, expectedDeploymentTime.Format(time.RFC3339))
+		err := fmt.Errorf("%s. deployed commit SHA %s ,github latest SHA %s, expected deployment timestamp: %s", ErrMsgDeploymentIsNotUpToDate, githubRepo.DeployedCommitSHA, latestCommit.SHA, expectedDeploymentTime.Format(time.RFC3339))
 		return NewComponentErrorCondition(toolchainv1alpha1.ToolchainStatusDeploymentNotUpToDateReason, err.Error())
 	}
 
 	// no problems with the deployment version, return a ready condition
 	return NewComponentReadyCondition(toolchainv1alpha1.ToolchainStatusDeploymentUpToDateReason)
 }
+
+// commitTimestamp returns the timestamp of the given commit, or the time when the commit was first returned by the commit source
+// if the latter does not provide the timestamps of the commits (eg, `GitCommitSource`)
+func (m *VersionCheckManager) commitTimestamp(githubRepo client.GitHubRepository, commit Commit) time.Time {
+	if !commit.Timestamp.IsZero() {
+		return commit.Timestamp
+	}
+	if m.firstSeenCommits == nil {
+		m.firstSeenCommits = map[string]Commit{}
+	}
+	if firstSeen, found := m.firstSeenCommits[githubRepo.Name]; found && firstSeen.SHA == commit.SHA {
+		return firstSeen.Timestamp
+	}
+	firstSeen := Commit{
+		SHA:       commit.SHA,
+		Timestamp: time.Now(),
+	}
+	m.firstSeenCommits[githubRepo.Name] = firstSeen
+	return firstSeen.Timestamp
+}
+
+// existingReadyCondition returns the existing ready condition, or an error condition if there is none
+func existingReadyCondition(alreadyExistingConditions []toolchainv1alpha1.Condition) *toolchainv1alpha1.Condition {
+	previouslySet, found := condition.FindConditionByType(alreadyExistingConditions, toolchainv1alpha1.ConditionReady)
+	if !found {
+		cond := NewComponentErrorCondition(toolchainv1alpha1.ToolchainStatusDeploymentRevisionCheckOperatorErrorReason, "unable to find ConditionReady type in existing conditions. Waiting for next attempt ...")
+		return cond
+	}
+	return &previouslySet
+}
@@ -2,6 +2,7 @@ package status
 
 import (
 	"net/http"
+	"strconv"
 	"testing"
 	"time"
 
@@ -83,6 +84,55 @@ func TestCheckDeployedVersionIsUpToDate(t *testing.T) {
 			test.AssertConditionsMatchAndRecentTimestamps(t, []toolchainv1alpha1.Condition{*conditions}, expected)
 		})
 
+		t.Run("we can issue a github api call after a custom delay", func(t *testing.T) {
+			// given
+			versionCheckMgrDelay := versionCheckMgr
+			versionCheckMgrDelay.GHCallsDelay = 10 * time.Second
+			versionCheckMgrDelay.LastGHCallsPerRepo = map[string]time.Time{
+				"host-operator": time.Now().Add(-11 * time.Second),
+			}
+			expected := toolchainv1alpha1.Condition{
+				Type:   toolchainv1alpha1.ConditionReady,
+				Status: corev1.ConditionTrue,
+				Reason: toolchainv1alpha1.ToolchainStatusDeploymentUpToDateReason,
+			}
+			alreadyExistingConditions := []toolchainv1alpha1.Condition{
+				{
+					Type:   toolchainv1alpha1.ConditionReady,
+					Status: corev1.ConditionFalse,
+					Reason: toolchainv1alpha1.ToolchainStatusDeploymentNotUpToDateReason,
+				},
+			}
+
+			// when
+			conditions := versionCheckMgrDelay.CheckDeployedVersionIsUpToDate(true, "githubToken", alreadyExistingConditions, githubRepo)
+
+			// then
+			test.AssertConditionsMatchAndRecentTimestamps(t, []toolchainv1alpha1.Condition{*conditions}, expected)
+		})
+
+		t.Run("github rate limit is exceeded but we return existing revision check condition", func(t *testing.T) {
+			// given
+			versionCheckMgrRateLimit := VersionCheckManager{
+				GetGithubClientFunc: func(string) *github.Client {
+					return github.NewClient(mockGitHubRateLimitExceeded())
+				},
+			}
+			expected := toolchainv1alpha1.Condition{
+				Type:               toolchainv1alpha1.ConditionReady,
+				Status:             corev1.ConditionTrue,
+				Reason:             toolchainv1alpha1.ToolchainStatusDeploymentUpToDateReason,
+				LastTransitionTime: metav1.Time{Time: time.Now()},
+				LastUpdatedTime:    &metav1.Time{Time: time.Now()},
+			}
+
+			// when
+			conditions := versionCheckMgrRateLimit.CheckDeployedVersionIsUpToDate(true, "githubToken", []toolchainv1alpha1.Condition{expected}, githubRepo)
+
+			// then
+			test.AssertConditionsMatchAndRecentTimestamps(t, []toolchainv1alpha1.Condition{*conditions}, expected)
+		})
+
 		t.Run("deployment version is up to date", func(t *testing.T) {
 			// given
 			expected := toolchainv1alpha1.Condition{
@@ -228,3 +278,17 @@ func TestCheckDeployedVersionIsUpToDate(t *testing.T) {
 		})
 	})
 }
+
+// mockGitHubRateLimitExceeded returns a client whose responses say that the rate limit is exceeded
+func mockGitHubRateLimitExceeded() *http.Client {
+	return mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			test.GetReposCommitsByOwnerByRepoByRef,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+				mock.WriteError(w, http.StatusForbidden, "API rate limit exceeded")
+			}),
+		),
+	)
+}
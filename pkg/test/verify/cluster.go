@@ -233,7 +233,9 @@ func newToolchainClusterService(t *testing.T, cl client.Client, withCA bool) clu
 			assert.False(t, config.Insecure)
 			assert.Equal(t, []byte("dummy"), config.CAData)
 		} else {
-			assert.True(t, config.Insecure)
+			// the certificate is verified against the system CA pool
+			assert.False(t, config.Insecure)
+			assert.Empty(t, config.CAData)
 		}
 		// make sure that insecure is false to make Gock mocking working properly
 		config.Insecure = false